@@ -0,0 +1,388 @@
+package gossie
+
+import (
+	"errors"
+	"github.com/carloscm/gossie/src/cassandra"
+	"github.com/pomack/thrift4go/lib/go/src/thrift"
+	"time"
+)
+
+var (
+	ErrorSchemaDisagreement     = errors.New("The cluster nodes do not agree on the schema version")
+	ErrorSchemaAgreementTimeout = errors.New("Timed out waiting for the cluster nodes to agree on the schema version")
+	ErrorCfNotFound             = errors.New("Column family not found")
+)
+
+// interval between checks of the schema versions while waiting for schema agreement
+const schemaAgreementPoll = 100 * time.Millisecond
+
+// Admin is the interface for the cluster description and administrative operations. All the calls
+// use the retry and timeout options of the connection pool.
+//
+// The schema changes wait for all the reachable nodes to agree on the schema version before and
+// after they are applied, for up to the pool SchemaTimeout each time. If the change is rejected
+// because of a schema disagreement it is tried again, up to the pool Retries. Schema changes can
+// take longer than a normal request, so the pool Timeout may need to be raised for them.
+type Admin interface {
+
+	// TruncateCf removes all the data from the passed column family in the keyspace of the pool.
+	// It requires all the nodes of the cluster to be up, and it may take longer than the pool
+	// Timeout in big column families.
+	TruncateCf(cf string) error
+
+	// ClusterName returns the name of the cluster
+	ClusterName() (string, error)
+
+	// Partitioner returns the class name of the partitioner used by the cluster
+	Partitioner() (string, error)
+
+	// Snitch returns the class name of the snitch used by the cluster
+	Snitch() (string, error)
+
+	// Ring returns the token ranges of the keyspace of the pool and the nodes they are replicated to
+	Ring() ([]*TokenRange, error)
+
+	// SchemaVersions returns the schema versions in use in the cluster, mapped to the addresses of
+	// the nodes using them. A cluster in schema agreement has a single version, unreachable nodes
+	// are listed under the "UNREACHABLE" key.
+	SchemaVersions() (map[string][]string, error)
+
+	// AddKeyspace creates a new keyspace, including the column families in the definition. The
+	// schema of the pool is refreshed if it is its keyspace.
+	AddKeyspace(*KeyspaceDefinition) error
+
+	// UpdateKeyspace changes the options of an existing keyspace. The schema of the pool is
+	// refreshed if it is its keyspace.
+	UpdateKeyspace(*KeyspaceDefinition) error
+
+	// DropKeyspace removes a keyspace and all its data. Dropping the keyspace of the pool leaves
+	// its schema as it was, since there is nothing left to refresh.
+	DropKeyspace(name string) error
+
+	// AddColumnFamily creates a new column family in the keyspace of the pool
+	AddColumnFamily(*ColumnFamilyDefinition) error
+
+	// UpdateColumnFamily changes an existing column family in the keyspace of the pool. The whole
+	// definition is replaced, so unset fields go back to their defaults and the named columns not
+	// present in the definition lose their metadata and indexes.
+	UpdateColumnFamily(*ColumnFamilyDefinition) error
+
+	// DropColumnFamily removes a column family and all its data from the keyspace of the pool
+	DropColumnFamily(name string) error
+
+	// PlanMigration returns the steps needed to bring the live schema of the keyspace of the pool up
+	// to the passed description, see the PlanMigration function. The description name may be empty.
+	PlanMigration(*KeyspaceDefinition) ([]*MigrationStep, error)
+
+	// Migrate plans and applies a migration, one step after another, and records the applied steps
+	// under the passed migration name in the MIGRATIONS_CF column family. It returns the applied
+	// steps, which on error are the ones applied before the failure.
+	Migrate(name string, desired *KeyspaceDefinition) ([]*MigrationStep, error)
+
+	// MigrationLog returns the descriptions of the steps recorded for a migration name, in the order
+	// they were applied
+	MigrationLog(name string) ([]string, error)
+}
+
+// TokenRange is a range of tokens of a keyspace and the nodes storing it
+type TokenRange struct {
+	StartToken      string
+	EndToken        string
+	Endpoints       []string
+	RpcEndpoints    []string
+	EndpointDetails []*EndpointDetails
+}
+
+// EndpointDetails stores the location of a node of the cluster
+type EndpointDetails struct {
+	Host       string
+	Datacenter string
+	Rack       string
+}
+
+type admin struct {
+	pool *connectionPool
+}
+
+func newAdmin(cp *connectionPool) *admin {
+	return &admin{pool: cp}
+}
+
+func (a *admin) TruncateCf(cf string) error {
+	return a.pool.run(func(c *connection) *transactionError {
+		ire, ue, err := c.client.Truncate(cf)
+		return &transactionError{ire: ire, ue: ue, err: err}
+	})
+}
+
+func (a *admin) describe(f func(*cassandra.CassandraClient) (string, error)) (string, error) {
+	var ret string
+	err := a.pool.run(func(c *connection) *transactionError {
+		var err error
+		ret, err = f(c.client)
+		return &transactionError{err: err}
+	})
+	if err != nil {
+		return "", err
+	}
+	return ret, nil
+}
+
+func (a *admin) ClusterName() (string, error) {
+	return a.describe(func(client *cassandra.CassandraClient) (string, error) {
+		return client.DescribeClusterName()
+	})
+}
+
+func (a *admin) Partitioner() (string, error) {
+	return a.describe(func(client *cassandra.CassandraClient) (string, error) {
+		return client.DescribePartitioner()
+	})
+}
+
+func (a *admin) Snitch() (string, error) {
+	return a.describe(func(client *cassandra.CassandraClient) (string, error) {
+		return client.DescribeSnitch()
+	})
+}
+
+func (a *admin) Ring() ([]*TokenRange, error) {
+	var ret thrift.TList
+	err := a.pool.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var err error
+		ret, ire, err = c.client.DescribeRing(a.pool.keyspace)
+		return &transactionError{ire: ire, err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]*TokenRange, 0)
+	if ret == nil {
+		return ranges, nil
+	}
+	for trI := range ret.Iter() {
+		// FIXME: this is weird, but happens a lot. thrift4go problem?
+		if trI == nil {
+			continue
+		}
+		tr := trI.(*cassandra.TokenRange)
+		r := &TokenRange{
+			StartToken:   tr.StartToken,
+			EndToken:     tr.EndToken,
+			Endpoints:    stringsFromTList(tr.Endpoints),
+			RpcEndpoints: stringsFromTList(tr.RpcEndpoints),
+		}
+		if tr.EndpointDetails != nil {
+			for edI := range tr.EndpointDetails.Iter() {
+				if edI == nil {
+					continue
+				}
+				ed := edI.(*cassandra.EndpointDetails)
+				r.EndpointDetails = append(r.EndpointDetails, &EndpointDetails{
+					Host:       ed.Host,
+					Datacenter: ed.Datacenter,
+					Rack:       ed.Rack,
+				})
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (a *admin) SchemaVersions() (map[string][]string, error) {
+	var ret thrift.TMap
+	err := a.pool.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var err error
+		ret, ire, err = c.client.DescribeSchemaVersions()
+		return &transactionError{ire: ire, err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string][]string)
+	if ret == nil {
+		return versions, nil
+	}
+	for vI := range ret.Iter() {
+		version, _ := vI.Key().(string)
+		hosts, _ := vI.Value().(thrift.TList)
+		versions[version] = stringsFromTList(hosts)
+	}
+	return versions, nil
+}
+
+func stringsFromTList(tl thrift.TList) []string {
+	r := make([]string, 0)
+	if tl == nil {
+		return r
+	}
+	for sI := range tl.Iter() {
+		if s, ok := sI.(string); ok {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+type schemaCall func(*cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error)
+
+// schemaChange runs f once the cluster is in schema agreement, trying it again while the change is
+// rejected because of a schema disagreement, and waits for the new schema to reach all the nodes
+func (a *admin) schemaChange(f schemaCall) error {
+	for tries := 0; tries < a.pool.options.Retries; tries++ {
+		if err := a.waitSchemaAgreement(); err != nil {
+			return err
+		}
+		disagreement := false
+		err := a.pool.run(func(c *connection) *transactionError {
+			_, ire, sde, err := f(c.client)
+			if sde != nil {
+				disagreement = true
+				return &transactionError{err: ErrorSchemaDisagreement}
+			}
+			return &transactionError{ire: ire, err: err}
+		})
+		if disagreement {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return a.waitSchemaAgreement()
+	}
+	return ErrorSchemaDisagreement
+}
+
+// cfSchemaChange runs a schema change over the keyspace of the pool and refreshes its schema
+func (a *admin) cfSchemaChange(f schemaCall) error {
+	if err := a.schemaChange(f); err != nil {
+		return err
+	}
+	_, err := a.pool.RefreshSchema()
+	return err
+}
+
+// ksSchemaChange runs a schema change over the named keyspace, refreshing the schema of the pool if
+// it is the keyspace of the pool
+func (a *admin) ksSchemaChange(name string, f schemaCall) error {
+	if name == a.pool.keyspace {
+		return a.cfSchemaChange(f)
+	}
+	return a.schemaChange(f)
+}
+
+func (a *admin) waitSchemaAgreement() error {
+	deadline := time.Now().Add(time.Duration(a.pool.options.SchemaTimeout) * time.Millisecond)
+	for {
+		versions, err := a.SchemaVersions()
+		if err != nil {
+			return err
+		}
+		if schemaAgreement(versions) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrorSchemaAgreementTimeout
+		}
+		time.Sleep(schemaAgreementPoll)
+	}
+}
+
+// schemaAgreement checks if all the reachable nodes have the same schema version
+func schemaAgreement(versions map[string][]string) bool {
+	n := 0
+	for version := range versions {
+		if version != "UNREACHABLE" {
+			n++
+		}
+	}
+	return n <= 1
+}
+
+// agreedSchemaVersion returns the schema version of the reachable nodes if they agree on it
+func agreedSchemaVersion(versions map[string][]string) (string, bool) {
+	agreed := ""
+	for version := range versions {
+		if version == "UNREACHABLE" {
+			continue
+		}
+		if agreed != "" {
+			return "", false
+		}
+		agreed = version
+	}
+	return agreed, agreed != ""
+}
+
+func (a *admin) AddKeyspace(d *KeyspaceDefinition) error {
+	ksDef := d.toCassandra()
+	return a.ksSchemaChange(d.Name, func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemAddKeyspace(ksDef)
+	})
+}
+
+func (a *admin) UpdateKeyspace(d *KeyspaceDefinition) error {
+	ksDef := d.toCassandra()
+	// column families cannot be changed in a keyspace update
+	ksDef.CfDefs = thrift.NewTList(thrift.STRUCT, 0)
+	return a.ksSchemaChange(d.Name, func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemUpdateKeyspace(ksDef)
+	})
+}
+
+func (a *admin) DropKeyspace(name string) error {
+	return a.schemaChange(func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemDropKeyspace(name)
+	})
+}
+
+func (a *admin) AddColumnFamily(d *ColumnFamilyDefinition) error {
+	cfDef := d.toCassandra(a.pool.keyspace)
+	return a.cfSchemaChange(func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemAddColumnFamily(cfDef)
+	})
+}
+
+func (a *admin) UpdateColumnFamily(d *ColumnFamilyDefinition) error {
+	cfDef := d.toCassandra(a.pool.keyspace)
+	// updates must carry the id of the existing column family
+	if cfDef.Id == 0 {
+		id, err := a.cfId(d.Name)
+		if err != nil {
+			return err
+		}
+		cfDef.Id = id
+	}
+	return a.cfSchemaChange(func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemUpdateColumnFamily(cfDef)
+	})
+}
+
+func (a *admin) DropColumnFamily(name string) error {
+	return a.cfSchemaChange(func(client *cassandra.CassandraClient) (string, *cassandra.InvalidRequestException, *cassandra.SchemaDisagreementException, error) {
+		return client.SystemDropColumnFamily(name)
+	})
+}
+
+func (a *admin) cfId(name string) (int32, error) {
+	ksDef, err := a.pool.describeKeyspace()
+	if err != nil {
+		return 0, err
+	}
+	for cfDefT := range ksDef.CfDefs.Iter() {
+		// FIXME: this is weird, but happens a lot. thrift4go problem?
+		if cfDefT == nil {
+			continue
+		}
+		cfDef, _ := cfDefT.(*cassandra.CfDef)
+		if cfDef.Name == name {
+			return cfDef.Id, nil
+		}
+	}
+	return 0, ErrorCfNotFound
+}
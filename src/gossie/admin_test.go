@@ -0,0 +1,199 @@
+package gossie
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	a := cp.Admin()
+
+	name, err := a.ClusterName()
+	if err != nil || name == "" {
+		t.Error("Unexpected cluster name: ", name, err)
+	}
+
+	partitioner, err := a.Partitioner()
+	if err != nil || !strings.HasSuffix(partitioner, "Partitioner") {
+		t.Error("Unexpected partitioner: ", partitioner, err)
+	}
+
+	snitch, err := a.Snitch()
+	if err != nil || !strings.Contains(snitch, "Snitch") {
+		t.Error("Unexpected snitch: ", snitch, err)
+	}
+
+	ring, err := a.Ring()
+	if err != nil {
+		t.Fatal("Error describing ring: ", err)
+	}
+	if len(ring) <= 0 {
+		t.Fatal("Expected at least one token range in the ring")
+	}
+	for _, tr := range ring {
+		if len(tr.Endpoints) <= 0 || len(tr.EndpointDetails) != len(tr.Endpoints) {
+			t.Error("Unexpected token range: ", tr)
+		}
+	}
+
+	versions, err := a.SchemaVersions()
+	if err != nil {
+		t.Fatal("Error describing schema versions: ", err)
+	}
+	if len(versions) != 1 {
+		t.Error("Expected a single schema version but got ", versions)
+	}
+
+	key := []byte("truncate")
+	if err = cp.Writer().Insert("ReasonableZero", &Row{Key: key, Columns: []*Column{&Column{Name: []byte("a"), Value: []byte("1")}}}).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	if err = a.TruncateCf("ReasonableZero"); err != nil {
+		t.Fatal("Error truncating column family: ", err)
+	}
+	exists, err := cp.Reader().Cf("ReasonableZero").Exists(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if exists {
+		t.Error("Row still exists after truncating its column family")
+	}
+
+	if err = a.TruncateCf("NotACf"); err == nil {
+		t.Error("Expected an error truncating an unknown column family")
+	}
+
+	// updating the keyspace of the pool refreshes its schema
+	ks := *cp.Schema().Definition
+	ks.DurableWrites = !ks.DurableWrites
+	if err = a.UpdateKeyspace(&ks); err != nil {
+		t.Fatal("Error updating keyspace: ", err)
+	}
+	if cp.Schema().Definition.DurableWrites != ks.DurableWrites {
+		t.Error("The schema of the pool was not refreshed after updating its keyspace")
+	}
+	ks.DurableWrites = !ks.DurableWrites
+	if err = a.UpdateKeyspace(&ks); err != nil {
+		t.Fatal("Error restoring keyspace: ", err)
+	}
+}
+
+func TestSchemaAgreement(t *testing.T) {
+	if !schemaAgreement(map[string][]string{"v1": []string{"127.0.0.1", "127.0.0.2"}}) {
+		t.Error("Expected agreement for a single version")
+	}
+	if !schemaAgreement(map[string][]string{"v1": []string{"127.0.0.1"}, "UNREACHABLE": []string{"127.0.0.2"}}) {
+		t.Error("Expected agreement ignoring unreachable nodes")
+	}
+	if schemaAgreement(map[string][]string{"v1": []string{"127.0.0.1"}, "v2": []string{"127.0.0.2"}}) {
+		t.Error("Expected disagreement for two versions")
+	}
+}
+
+func TestAgreedSchemaVersion(t *testing.T) {
+	if v, agreed := agreedSchemaVersion(map[string][]string{"v1": []string{"127.0.0.1"}, "UNREACHABLE": []string{"127.0.0.2"}}); !agreed || v != "v1" {
+		t.Error("Expected agreement on v1 but got ", v, agreed)
+	}
+	if _, agreed := agreedSchemaVersion(map[string][]string{"v1": []string{"127.0.0.1"}, "v2": []string{"127.0.0.2"}}); agreed {
+		t.Error("Expected disagreement for two versions")
+	}
+	if _, agreed := agreedSchemaVersion(map[string][]string{}); agreed {
+		t.Error("Expected no agreement without versions")
+	}
+}
+
+func TestSchemaManagement(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: 10000})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	a := cp.Admin()
+
+	ks := &KeyspaceDefinition{
+		Name:            "TestGossieSchema",
+		StrategyOptions: map[string]string{"replication_factor": "1"},
+		DurableWrites:   true,
+		ColumnFamilies: []*ColumnFamilyDefinition{
+			&ColumnFamilyDefinition{
+				Name:                   "Tweets",
+				Comparator:             "UTF8Type",
+				KeyValidationClass:     "UTF8Type",
+				DefaultValidationClass: "BytesType",
+				Columns: []*ColumnDefinition{
+					&ColumnDefinition{Name: []byte("Author"), ValidationClass: "UTF8Type", IndexType: KeysIndex},
+				},
+			},
+		},
+	}
+
+	// leftovers from a failed run
+	a.DropKeyspace(ks.Name)
+
+	if err = a.AddKeyspace(ks); err != nil {
+		t.Fatal("Error adding keyspace: ", err)
+	}
+	ks.DurableWrites = false
+	if err = a.UpdateKeyspace(ks); err != nil {
+		t.Error("Error updating keyspace: ", err)
+	}
+
+	kp, err := NewConnectionPool(localEndpointPool, ks.Name, PoolOptions{Size: 1, Timeout: 10000})
+	if err != nil {
+		t.Fatal("Error connecting to the new keyspace:", err)
+	}
+	defer kp.Close()
+
+	tweets := kp.Schema().ColumnFamilies["Tweets"]
+	if tweets == nil {
+		t.Fatal("Column family in the keyspace definition was not created")
+	}
+	if tweets.DefaultComparator.Desc != UTF8Type || tweets.NamedColumns["Author"].Desc != UTF8Type {
+		t.Error("Unexpected column family schema: ", tweets)
+	}
+
+	ka := kp.Admin()
+	cf := &ColumnFamilyDefinition{
+		Name:                   "Counters",
+		KeyValidationClass:     "UTF8Type",
+		DefaultValidationClass: "CounterColumnType",
+	}
+	if err = ka.AddColumnFamily(cf); err != nil {
+		t.Fatal("Error adding column family: ", err)
+	}
+	cf.Comment = "updated"
+	if err = ka.UpdateColumnFamily(cf); err != nil {
+		t.Error("Error updating column family: ", err)
+	}
+	if err = ka.UpdateColumnFamily(&ColumnFamilyDefinition{Name: "NotACf"}); err != ErrorCfNotFound {
+		t.Error("Expected ErrorCfNotFound updating an unknown column family but got ", err)
+	}
+	if err = ka.AddColumnFamily(cf); err == nil {
+		t.Error("Expected an error adding an existing column family")
+	}
+
+	if err = kp.Writer().DeltaCounters("Counters", &Row{Key: []byte("key"), Columns: []*Column{&Column{Name: []byte("c"), Value: []byte{0, 0, 0, 0, 0, 0, 0, 1}}}}).Run(); err != nil {
+		t.Error("Error writing to the new column family: ", err)
+	}
+
+	if err = ka.DropColumnFamily("Counters"); err != nil {
+		t.Error("Error dropping column family: ", err)
+	}
+	versions, err := a.SchemaVersions()
+	if err != nil || !schemaAgreement(versions) {
+		t.Error("Expected schema agreement after a schema change: ", versions, err)
+	}
+
+	kp.Close()
+	if err = a.DropKeyspace(ks.Name); err != nil {
+		t.Error("Error dropping keyspace: ", err)
+	}
+	if _, err = NewConnectionPool(localEndpointPool, ks.Name, PoolOptions{Size: 1, Timeout: 10000}); err == nil {
+		t.Error("Expected an error connecting to a dropped keyspace")
+	}
+}
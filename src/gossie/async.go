@@ -0,0 +1,243 @@
+package gossie
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	DEFAULT_ASYNC_QUEUE = 1000
+)
+
+var (
+	ErrorAsyncQueueFull = errors.New("Async write queue is full")
+	ErrorAsyncClosed    = errors.New("Async writes are closed")
+)
+
+// AsyncOptions stores the options for the creation of an Async
+type AsyncOptions struct {
+	Queue   int  // maximum number of queued writes, defaults to DEFAULT_ASYNC_QUEUE
+	Workers int  // number of background writes running at the same time, defaults to the pool Size
+	Block   bool // if true, queueing waits for free space instead of failing with ErrorAsyncQueueFull
+}
+
+// Runner is implemented by Writer and Batch
+type Runner interface {
+	Run() error
+}
+
+// Future is the pending result of a write queued with Async.Submit
+type Future interface {
+
+	// Done returns a channel that is closed when the write has finished
+	Done() <-chan bool
+
+	// Wait waits for the write to finish and returns its result
+	Wait() error
+}
+
+// Async runs writes in the background, taking them from a bounded queue served by a fixed number
+// of workers using connections of the pool. Its methods are safe to be called from several
+// goroutines at the same time.
+type Async interface {
+
+	// Writer returns a Writer whose Run queues the mutations and returns at once. A nil return
+	// value only means the mutations were queued, errors found while running them are sent to
+	// the Errors channel.
+	Writer() Writer
+
+	// Batch returns a Batch whose Run queues the mutations and returns at once, with the same
+	// semantics as Writer. Mapping errors are still returned by Run.
+	Batch() Batch
+
+	// Submit queues the passed Writer or Batch and returns a Future for its result instead of
+	// reporting it to the Errors channel.
+	Submit(Runner) Future
+
+	// Errors returns the channel where errors from the writes queued by Writer and Batch are
+	// sent. Errors are discarded if the channel is full, so it must be read continuously to
+	// receive all of them. It is closed by Close.
+	Errors() <-chan error
+
+	// Flush waits for all the writes queued so far to finish
+	Flush()
+
+	// Close waits for all the queued writes to finish and stops the workers. Queueing new writes
+	// after calling Close fails with ErrorAsyncClosed.
+	Close()
+}
+
+func (o *AsyncOptions) defaults(cp *connectionPool) {
+	if o.Queue == 0 {
+		o.Queue = DEFAULT_ASYNC_QUEUE
+	}
+	if o.Workers == 0 {
+		o.Workers = cp.options.Size
+	}
+}
+
+type future struct {
+	done chan bool
+	err  error
+}
+
+func newFuture() *future {
+	return &future{done: make(chan bool)}
+}
+
+func (f *future) finish(err error) {
+	f.err = err
+	close(f.done)
+}
+
+func (f *future) Done() <-chan bool {
+	return f.done
+}
+
+func (f *future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+type runnerFunc func() error
+
+func (f runnerFunc) Run() error {
+	return f()
+}
+
+type asyncJob struct {
+	runner Runner
+	future *future
+}
+
+type async struct {
+	pool    *connectionPool
+	options AsyncOptions
+	jobs    chan *asyncJob
+	errors  chan error
+	workers sync.WaitGroup
+
+	// guards closed and the sending of jobs against Close
+	mutex  sync.RWMutex
+	closed bool
+
+	// number of queued or running jobs, guarded by pendingMutex
+	pendingMutex sync.Mutex
+	finished     *sync.Cond
+	pending      int
+}
+
+func newAsync(cp *connectionPool, options AsyncOptions) *async {
+	options.defaults(cp)
+	a := &async{
+		pool:    cp,
+		options: options,
+		jobs:    make(chan *asyncJob, options.Queue),
+		errors:  make(chan error, options.Queue),
+	}
+	a.finished = sync.NewCond(&a.pendingMutex)
+	a.workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go a.work()
+	}
+	return a
+}
+
+func (a *async) work() {
+	defer a.workers.Done()
+	for job := range a.jobs {
+		err := job.runner.Run()
+		if job.future != nil {
+			job.future.finish(err)
+		} else if err != nil {
+			select {
+			case a.errors <- err:
+			default:
+			}
+		}
+		a.pendingMutex.Lock()
+		a.pending--
+		a.pendingMutex.Unlock()
+		a.finished.Broadcast()
+	}
+}
+
+func (a *async) enqueue(job *asyncJob) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		return ErrorAsyncClosed
+	}
+
+	a.pendingMutex.Lock()
+	a.pending++
+	a.pendingMutex.Unlock()
+
+	if a.options.Block {
+		a.jobs <- job
+		return nil
+	}
+	select {
+	case a.jobs <- job:
+		return nil
+	default:
+	}
+
+	a.pendingMutex.Lock()
+	a.pending--
+	a.pendingMutex.Unlock()
+	a.finished.Broadcast()
+	return ErrorAsyncQueueFull
+}
+
+func (a *async) Writer() Writer {
+	w := newWriter(a.pool, a.pool.options.WriteConsistency)
+	w.async = a
+	return w
+}
+
+func (a *async) Batch() Batch {
+	return newBatch(a.pool, a.Writer())
+}
+
+func (a *async) Submit(r Runner) Future {
+	// writers and batches created by an Async would queue themselves again when run by a worker
+	switch t := r.(type) {
+	case *writer:
+		t.async = nil
+	case *batch:
+		if w, ok := t.writer.(*writer); ok {
+			w.async = nil
+		}
+	}
+	f := newFuture()
+	if err := a.enqueue(&asyncJob{runner: r, future: f}); err != nil {
+		f.finish(err)
+	}
+	return f
+}
+
+func (a *async) Errors() <-chan error {
+	return a.errors
+}
+
+func (a *async) Flush() {
+	a.pendingMutex.Lock()
+	defer a.pendingMutex.Unlock()
+	for a.pending > 0 {
+		a.finished.Wait()
+	}
+}
+
+func (a *async) Close() {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return
+	}
+	a.closed = true
+	close(a.jobs)
+	a.mutex.Unlock()
+	a.workers.Wait()
+	close(a.errors)
+}
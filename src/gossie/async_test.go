@@ -0,0 +1,121 @@
+package gossie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAsyncQueue(t *testing.T) {
+	a := newAsync(&connectionPool{options: PoolOptions{Size: 1}}, AsyncOptions{Queue: 1})
+
+	started := make(chan bool)
+	release := make(chan bool)
+	first := a.Submit(runnerFunc(func() error {
+		started <- true
+		<-release
+		return nil
+	}))
+	<-started
+
+	failure := errors.New("failure")
+	second := a.Submit(runnerFunc(func() error {
+		return failure
+	}))
+	third := a.Submit(runnerFunc(func() error {
+		return nil
+	}))
+	if err := third.Wait(); err != ErrorAsyncQueueFull {
+		t.Error("Expected ErrorAsyncQueueFull but got ", err)
+	}
+
+	select {
+	case <-first.Done():
+		t.Error("Future finished before its write")
+	default:
+	}
+
+	close(release)
+	a.Flush()
+	if err := first.Wait(); err != nil {
+		t.Error("Unexpected error in future: ", err)
+	}
+	if err := second.Wait(); err != failure {
+		t.Error("Expected the write error in future but got ", err)
+	}
+
+	err := a.enqueue(&asyncJob{runner: runnerFunc(func() error {
+		return failure
+	})})
+	if err != nil {
+		t.Error("Unexpected error queueing write: ", err)
+	}
+	a.Close()
+	if err := <-a.Errors(); err != failure {
+		t.Error("Expected the write error in the errors channel but got ", err)
+	}
+	if _, open := <-a.Errors(); open {
+		t.Error("Errors channel was not closed")
+	}
+
+	if err := a.Submit(runnerFunc(func() error { return nil })).Wait(); err != ErrorAsyncClosed {
+		t.Error("Expected ErrorAsyncClosed but got ", err)
+	}
+}
+
+func TestAsync(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 2, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+
+	m, err := NewMapping(&ReasonableZero{})
+	if err != nil {
+		t.Fatal("Error building mapping:", err)
+	}
+
+	a := cp.Async(AsyncOptions{Block: true})
+
+	if err = a.Writer().Insert("AllTypes", buildAllTypesRow("async0")).Run(); err != nil {
+		t.Error("Error queueing async write:", err)
+	}
+	r := &ReasonableZero{"asynctest", 1.00002, -38.11, "hey this thing appears to work, nice!"}
+	if err = a.Batch().Insert(m, r).Run(); err != nil {
+		t.Error("Error queueing async batch:", err)
+	}
+	f := a.Submit(cp.Writer().Insert("AllTypes", buildAllTypesRow("async1")))
+	if err = f.Wait(); err != nil {
+		t.Error("Error in async write future:", err)
+	}
+
+	a.Flush()
+
+	for _, key := range []string{"async0", "async1"} {
+		exists, err := cp.Reader().Cf("AllTypes").Exists([]byte(key))
+		if err != nil {
+			t.Fatal("Error reading async written row:", err)
+		}
+		if !exists {
+			t.Error("Async written row ", key, " was not found")
+		}
+	}
+	rs := &ReasonableZero{}
+	result, err := cp.Query(m).Get("asynctest")
+	if err != nil {
+		t.Fatal("Error reading async batch:", err)
+	}
+	if err = result.Next(rs); err != nil {
+		t.Error("Error reading async batch:", err)
+	}
+	if !reflect.DeepEqual(r, rs) {
+		t.Error("Read struct does not match: ", r, rs)
+	}
+
+	a.Writer().Delete("AllTypes", []byte("async0")).Run()
+	a.Writer().Delete("AllTypes", []byte("async1")).Run()
+	a.Batch().DeleteAll(m, r).Run()
+	a.Close()
+	for err = range a.Errors() {
+		t.Error("Error in async write:", err)
+	}
+}
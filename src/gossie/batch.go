@@ -1,5 +1,10 @@
 package gossie
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Batch is a high level interface for Cassandra writes. Simultaneous
 // insertions for different column families and keys are possible.
 type Batch interface {
@@ -9,13 +14,27 @@ type Batch interface {
 	// pool options value.
 	ConsistencyLevel(int) Batch
 
+	// Timestamp sets a fixed timestamp for the insertions and deletions added after this call. By
+	// default the connection pool TimestampProvider is used.
+	Timestamp(int64) Batch
+
 	// Ttl sets a time to live for the columns inserted by Insert(). It is 0
 	// by default which means no TTL.
 	Ttl(int) Batch
 
+	// Strict set to true validates the mappings passed after this call against the keyspace schema,
+	// see ConnectionPool.ValidateMapping. The first *MappingError found is returned by Run.
+	Strict(bool) Batch
+
 	// Insert adds new data to be inserted
 	Insert(mapping Mapping, data interface{}) Batch
 
+	// Increment adds the values of the passed struct to the counters of its row, for column
+	// families storing counters. All the value fields must be integers marshaled as LongType, which
+	// is the default for Go integer types. A Batch with increments is never retried, see
+	// COUNTER_ATTEMPTS.
+	Increment(mapping Mapping, data interface{}) Batch
+
 	// Delete marks only the specific columns of the passed struct to be
 	// deleted (respecting the composites).
 	Delete(mapping Mapping, data interface{}) Batch
@@ -25,6 +44,9 @@ type Batch interface {
 	// family is using composites.
 	DeleteAll(mapping Mapping, data interface{}) Batch
 
+	// DryRun validates this batch against the keyspace schema without sending it
+	DryRun() error
+
 	// Run this batch
 	Run() error
 }
@@ -34,13 +56,14 @@ type batch struct {
 	writer           Writer
 	consistencyLevel int
 	ttl              int
+	strict           bool
 	mappingError     error
 }
 
-func newBatch(cp *connectionPool) *batch {
+func newBatch(cp *connectionPool, w Writer) *batch {
 	return &batch{
 		pool:   cp,
-		writer: cp.Writer(),
+		writer: w,
 	}
 }
 
@@ -49,13 +72,31 @@ func (b *batch) ConsistencyLevel(c int) Batch {
 	return b
 }
 
+func (b *batch) Timestamp(timestamp int64) Batch {
+	b.writer.Timestamps(NewFixedClock(timestamp))
+	return b
+}
+
 func (b *batch) Ttl(ttl int) Batch {
 	b.ttl = ttl
 	return b
 }
 
+func (b *batch) Strict(s bool) Batch {
+	b.strict = s
+	return b
+}
+
+// validate checks the mapping against the schema in strict mode, keeping the first error
+func (b *batch) validate(mapping Mapping) bool {
+	if b.mappingError == nil && b.strict {
+		b.mappingError = b.pool.ValidateMapping(mapping)
+	}
+	return b.mappingError == nil
+}
+
 func (b *batch) Insert(mapping Mapping, data interface{}) Batch {
-	if b.mappingError == nil {
+	if b.validate(mapping) {
 		row, err := mapping.Map(data)
 		if err == nil {
 			if b.ttl > 0 {
@@ -70,8 +111,27 @@ func (b *batch) Insert(mapping Mapping, data interface{}) Batch {
 	return b
 }
 
+func (b *batch) Increment(mapping Mapping, data interface{}) Batch {
+	if b.validate(mapping) {
+		row, err := mapping.Map(data)
+		if err != nil {
+			b.mappingError = err
+			return b
+		}
+		for _, col := range row.Columns {
+			var delta int64
+			if err := Unmarshal(col.Value, LongType, &delta); err != nil {
+				b.mappingError = errors.New(fmt.Sprint("Increment requires integer values marshaled as LongType, error in column ", col.Name, ": ", err))
+				return b
+			}
+		}
+		b.writer.DeltaCounters(mapping.Cf(), row)
+	}
+	return b
+}
+
 func (b *batch) Delete(mapping Mapping, data interface{}) Batch {
-	if b.mappingError == nil {
+	if b.validate(mapping) {
 		row, err := mapping.Map(data)
 		if err == nil {
 			b.writer.DeleteColumns(mapping.Cf(), row.Key, row.ColumnNames())
@@ -83,7 +143,7 @@ func (b *batch) Delete(mapping Mapping, data interface{}) Batch {
 }
 
 func (b *batch) DeleteAll(mapping Mapping, data interface{}) Batch {
-	if b.mappingError == nil {
+	if b.validate(mapping) {
 		row, err := mapping.Map(data)
 		if err == nil {
 			b.writer.Delete(mapping.Cf(), row.Key)
@@ -94,6 +154,13 @@ func (b *batch) DeleteAll(mapping Mapping, data interface{}) Batch {
 	return b
 }
 
+func (b *batch) DryRun() error {
+	if b.mappingError != nil {
+		return b.mappingError
+	}
+	return b.writer.DryRun()
+}
+
 func (b *batch) Run() error {
 	if b.mappingError != nil {
 		return b.mappingError
@@ -157,3 +157,117 @@ func TestBatch(t *testing.T) {
 	}
 
 }
+
+func TestBatchTimestamp(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	m0, err := NewMapping(&ReasonableZero{})
+	if err != nil {
+		t.Fatal("Error building mapping:", err)
+	}
+
+	r := &ReasonableZero{"batchtimestamp", 1.00002, -38.11, "hey this thing appears to work, nice!"}
+	ts := now()
+
+	err = cp.Batch().DeleteAll(m0, r).Run()
+	if err != nil {
+		t.Fatal("Error writing:", err)
+	}
+	err = cp.Batch().Timestamp(ts).Insert(m0, r).Run()
+	if err != nil {
+		t.Fatal("Error writing:", err)
+	}
+
+	row, err := cp.Reader().Cf("ReasonableZero").Get([]byte("batchtimestamp"))
+	if err != nil {
+		t.Fatal("Error reading:", err)
+	}
+	if row == nil || len(row.Columns) != 3 {
+		t.Fatal("Unexpected row:", row)
+	}
+	for _, c := range row.Columns {
+		if c.Timestamp != ts {
+			t.Error("Column ", string(c.Name), " has timestamp ", c.Timestamp, " instead of ", ts)
+		}
+	}
+
+	// a deletion older than the insertion does not delete anything
+	err = cp.Batch().Timestamp(ts-1).DeleteAll(m0, r).Run()
+	if err != nil {
+		t.Fatal("Error writing:", err)
+	}
+	count, err := cp.Reader().Cf("ReasonableZero").Count([]byte("batchtimestamp"))
+	if err != nil {
+		t.Fatal("Error reading:", err)
+	}
+	if count != 3 {
+		t.Error("Expected 3 columns after an older deletion, got ", count)
+	}
+}
+
+type BatchCounters struct {
+	Key    string `cf:"Counters" key:"Key"`
+	Views  int64
+	Clicks int
+}
+
+func TestBatchIncrement(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	m, err := NewMapping(&BatchCounters{})
+	if err != nil {
+		t.Fatal("Error building mapping:", err)
+	}
+
+	key := []byte("batchincrement")
+	if err = cp.Writer().RemoveCounter("Counters", key, nil).Run(); err != nil {
+		t.Fatal("Error removing counters:", err)
+	}
+
+	err = cp.Batch().Increment(m, &BatchCounters{"batchincrement", 10, -1}).Increment(m, &BatchCounters{"batchincrement", 5, 3}).Run()
+	if err != nil {
+		t.Fatal("Error running batch:", err)
+	}
+
+	views, err := cp.Reader().Cf("Counters").GetCounter(key, []byte("Views"))
+	if err != nil {
+		t.Fatal("Error reading counter:", err)
+	}
+	clicks, err := cp.Reader().Cf("Counters").GetCounter(key, []byte("Clicks"))
+	if err != nil {
+		t.Fatal("Error reading counter:", err)
+	}
+	if views != 15 || clicks != 2 {
+		t.Error("Unexpected counter values after increments: ", views, clicks)
+	}
+
+	read := &BatchCounters{}
+	result, err := cp.Query(m).Get("batchincrement")
+	if err != nil {
+		t.Fatal("Error reading counters:", err)
+	}
+	if err = result.Next(read); err != nil {
+		t.Fatal("Error reading counters:", err)
+	}
+	if read.Views != 15 || read.Clicks != 2 {
+		t.Error("Unexpected mapped counter values: ", read)
+	}
+
+	m0, err := NewMapping(&ReasonableZero{})
+	if err != nil {
+		t.Fatal("Error building mapping:", err)
+	}
+	if err = cp.Batch().Increment(m0, &ReasonableZero{"batchincrement", 1, 2, "body"}).Run(); err == nil {
+		t.Error("Expected an error incrementing non integer fields")
+	}
+
+	cp.Writer().RemoveCounter("Counters", key, nil).Run()
+}
@@ -0,0 +1,281 @@
+package gossie
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BULK_MUTATIONS   = 1000
+	DEFAULT_BULK_BYTES       = 4 * 1024 * 1024
+	DEFAULT_BULK_CONCURRENCY = 1
+)
+
+// estimated overhead in bytes of a column in a batch_mutate call, for the Thrift field headers,
+// timestamp and ttl
+const bulkColumnOverhead = 32
+
+// BulkOptions stores the options for the creation of a BulkWriter
+type BulkOptions struct {
+	Mutations        int              // flush after buffering Mutations mutations, repeated writes of a column count once
+	Bytes            int              // flush after buffering an estimated Bytes bytes of mutations
+	Interval         int              // if set, flush the buffered mutations every Interval ms
+	Concurrency      int              // run up to Concurrency flushes at the same time
+	ConsistencyLevel int              // write consistency, defaults to your connection pool options value
+	OnFlush          func(*BulkFlush) // if set, called after every flush with its results
+}
+
+// BulkStats stores the totals of a BulkWriter
+type BulkStats struct {
+	Flushes   int // number of finished flushes
+	Failed    int // number of finished flushes that returned an error
+	Mutations int // number of sent mutations, including the ones in failed flushes
+	Bytes     int // estimated size of the added rows, including the ones in failed flushes
+}
+
+// BulkFlush describes a single finished flush of a BulkWriter
+type BulkFlush struct {
+	Mutations int       // number of mutations in this flush
+	Bytes     int       // estimated size of the mutations in this flush
+	Err       error     // error returned while running this flush, if any
+	Totals    BulkStats // totals of the BulkWriter, including this flush
+}
+
+// BulkWriter is a write interface for loading large amounts of data. It buffers mutations and
+// sends them automatically in batches, bounded by number of mutations, size and time. The
+// methods are safe to be called from several goroutines at the same time. Adding mutations blocks
+// while the maximum number of concurrent flushes are running.
+type BulkWriter interface {
+
+	// Insert adds a new row insertion
+	Insert(cf string, row *Row) BulkWriter
+
+	// InsertTtl adds a new row insertion, overriding the columns Ttl with the passed value
+	InsertTtl(cf string, row *Row, ttl int) BulkWriter
+
+	// DeltaCounters add a new delta operation over counters. Flushes containing counter
+	// operations are not retried.
+	DeltaCounters(cf string, row *Row) BulkWriter
+
+	// Delete deletes a single row specified by key
+	Delete(cf string, key []byte) BulkWriter
+
+	// DeleteColumns deletes the passed columns from the row specified by key
+	DeleteColumns(cf string, key []byte, columns [][]byte) BulkWriter
+
+	// Flush sends the buffered mutations and waits for all the running flushes to finish. It
+	// returns the first error found in any flush so far.
+	Flush() error
+
+	// Stats returns the totals of the finished flushes so far
+	Stats() BulkStats
+
+	// Close flushes the buffered mutations, waits for all the running flushes to finish and stops
+	// the timed flushes. It returns the final totals and the first error found in any flush. The
+	// BulkWriter cannot be used after calling Close, but calling Close again is safe and returns
+	// the same totals and error.
+	Close() (BulkStats, error)
+}
+
+func (o *BulkOptions) defaults(cp *connectionPool) {
+	if o.Mutations == 0 {
+		o.Mutations = DEFAULT_BULK_MUTATIONS
+	}
+	if o.Bytes == 0 {
+		o.Bytes = DEFAULT_BULK_BYTES
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = DEFAULT_BULK_CONCURRENCY
+	}
+	if o.ConsistencyLevel == 0 {
+		o.ConsistencyLevel = cp.options.WriteConsistency
+	}
+}
+
+type bulkBatch struct {
+	writer    *writer
+	mutations int
+	bytes     int
+}
+
+type bulkWriter struct {
+	pool    *connectionPool
+	options BulkOptions
+
+	// buffered mutations
+	mutex   sync.Mutex
+	pending *bulkBatch
+
+	// running flushes
+	slots    chan bool
+	stop     chan bool
+	stopOnce sync.Once
+
+	// results and number of running flushes, guarded by statsMutex
+	statsMutex sync.Mutex
+	finished   *sync.Cond
+	running    int
+	stats      BulkStats
+	err        error
+}
+
+func newBulkWriter(cp *connectionPool, options BulkOptions) *bulkWriter {
+	options.defaults(cp)
+	b := &bulkWriter{
+		pool:    cp,
+		options: options,
+		slots:   make(chan bool, options.Concurrency),
+		stop:    make(chan bool),
+	}
+	b.finished = sync.NewCond(&b.statsMutex)
+	b.pending = b.newBatch()
+	if options.Interval > 0 {
+		go b.tick(time.Duration(options.Interval) * time.Millisecond)
+	}
+	return b
+}
+
+func (b *bulkWriter) newBatch() *bulkBatch {
+	return &bulkBatch{writer: newWriter(b.pool, b.options.ConsistencyLevel)}
+}
+
+func rowSize(row *Row) int {
+	size := len(row.Key)
+	for _, c := range row.Columns {
+		size += len(c.Name) + len(c.Value) + bulkColumnOverhead
+	}
+	return size
+}
+
+// add runs f over the buffered writer and flushes it if any of the limits is reached. Mutations
+// are counted from the writer, which merges repeated writes of the same column, while bytes are
+// estimated from the added rows.
+func (b *bulkWriter) add(bytes int, f func(w *writer)) BulkWriter {
+	var full *bulkBatch
+	b.mutex.Lock()
+	before := len(b.pending.writer.order)
+	f(b.pending.writer)
+	b.pending.mutations += len(b.pending.writer.order) - before
+	b.pending.bytes += bytes
+	if b.pending.mutations >= b.options.Mutations || b.pending.bytes >= b.options.Bytes {
+		full = b.pending
+		b.pending = b.newBatch()
+	}
+	b.mutex.Unlock()
+	if full != nil {
+		b.send(full)
+	}
+	return b
+}
+
+func (b *bulkWriter) Insert(cf string, row *Row) BulkWriter {
+	return b.add(rowSize(row), func(w *writer) { w.Insert(cf, row) })
+}
+
+func (b *bulkWriter) InsertTtl(cf string, row *Row, ttl int) BulkWriter {
+	return b.add(rowSize(row), func(w *writer) { w.InsertTtl(cf, row, ttl) })
+}
+
+func (b *bulkWriter) DeltaCounters(cf string, row *Row) BulkWriter {
+	return b.add(rowSize(row), func(w *writer) { w.DeltaCounters(cf, row) })
+}
+
+func (b *bulkWriter) Delete(cf string, key []byte) BulkWriter {
+	return b.add(len(key)+bulkColumnOverhead, func(w *writer) { w.Delete(cf, key) })
+}
+
+func (b *bulkWriter) DeleteColumns(cf string, key []byte, columns [][]byte) BulkWriter {
+	size := len(key) + bulkColumnOverhead
+	for _, c := range columns {
+		size += len(c)
+	}
+	return b.add(size, func(w *writer) { w.DeleteColumns(cf, key, columns) })
+}
+
+// send runs the passed batch in the background, waiting first for a free flush slot
+func (b *bulkWriter) send(batch *bulkBatch) {
+	b.slots <- true
+	b.statsMutex.Lock()
+	b.running++
+	b.statsMutex.Unlock()
+	go func() {
+		err := batch.writer.Run()
+		<-b.slots
+		b.report(batch, err)
+	}()
+}
+
+func (b *bulkWriter) report(batch *bulkBatch, err error) {
+	b.statsMutex.Lock()
+	b.stats.Flushes++
+	b.stats.Mutations += batch.mutations
+	b.stats.Bytes += batch.bytes
+	if err != nil {
+		b.stats.Failed++
+		if b.err == nil {
+			b.err = err
+		}
+	}
+	flush := &BulkFlush{
+		Mutations: batch.mutations,
+		Bytes:     batch.bytes,
+		Err:       err,
+		Totals:    b.stats,
+	}
+	b.statsMutex.Unlock()
+	if b.options.OnFlush != nil {
+		b.options.OnFlush(flush)
+	}
+	b.statsMutex.Lock()
+	b.running--
+	b.statsMutex.Unlock()
+	b.finished.Broadcast()
+}
+
+func (b *bulkWriter) flushPending() {
+	var batch *bulkBatch
+	b.mutex.Lock()
+	if b.pending.mutations > 0 {
+		batch = b.pending
+		b.pending = b.newBatch()
+	}
+	b.mutex.Unlock()
+	if batch != nil {
+		b.send(batch)
+	}
+}
+
+func (b *bulkWriter) tick(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.flushPending()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *bulkWriter) Flush() error {
+	b.flushPending()
+	b.statsMutex.Lock()
+	defer b.statsMutex.Unlock()
+	for b.running > 0 {
+		b.finished.Wait()
+	}
+	return b.err
+}
+
+func (b *bulkWriter) Stats() BulkStats {
+	b.statsMutex.Lock()
+	defer b.statsMutex.Unlock()
+	return b.stats
+}
+
+func (b *bulkWriter) Close() (BulkStats, error) {
+	b.stopOnce.Do(func() { close(b.stop) })
+	err := b.Flush()
+	return b.Stats(), err
+}
@@ -0,0 +1,124 @@
+package gossie
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestBulkRowSize(t *testing.T) {
+	row := &Row{
+		Key: []byte("key"),
+		Columns: []*Column{
+			&Column{Name: []byte("a"), Value: []byte("12345")},
+			&Column{Name: []byte("bb"), Value: []byte{}},
+		},
+	}
+	expected := 3 + (1 + 5 + bulkColumnOverhead) + (2 + bulkColumnOverhead)
+	if s := rowSize(row); s != expected {
+		t.Error("Unexpected row size estimation ", s, " instead of ", expected)
+	}
+}
+
+func TestBulkClose(t *testing.T) {
+	b := newBulkWriter(&connectionPool{}, BulkOptions{Interval: 10})
+	stats, err := b.Close()
+	if err != nil {
+		t.Error("Error closing BulkWriter: ", err)
+	}
+	// closing again, for example from a deferred Close, does not panic
+	again, err := b.Close()
+	if err != nil || again != stats {
+		t.Error("Unexpected result closing BulkWriter again: ", again, err)
+	}
+}
+
+func TestBulkWriter(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 4, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+
+	var lock sync.Mutex
+	flushes := 0
+	flushed := 0
+	b := cp.BulkWriter(BulkOptions{
+		Mutations:   100,
+		Concurrency: 4,
+		OnFlush: func(f *BulkFlush) {
+			lock.Lock()
+			defer lock.Unlock()
+			if f.Err != nil {
+				t.Error("Error in bulk flush: ", f.Err)
+			}
+			if f.Mutations >= 100+len(buildAllTypesRow("bulk0").Columns) {
+				t.Error("Bulk flush exceeded the mutation limit: ", f.Mutations)
+			}
+			flushes++
+			flushed += f.Mutations
+		},
+	})
+
+	rows := 500
+	for i := 0; i < rows; i++ {
+		b.Insert("AllTypes", buildAllTypesRow(fmt.Sprint("bulk", i)))
+	}
+	b.DeltaCounters("Counters", buildCounterRow("bulk0"))
+
+	stats, err := b.Close()
+	if err != nil {
+		t.Fatal("Error closing bulk writer:", err)
+	}
+
+	columns := rows*len(buildAllTypesRow("bulk0").Columns) + len(buildCounterRow("bulk0").Columns)
+	if stats.Mutations != columns {
+		t.Error("Bulk writer sent ", stats.Mutations, " mutations instead of ", columns)
+	}
+	if stats.Failed != 0 {
+		t.Error("Bulk writer reported ", stats.Failed, " failed flushes")
+	}
+	if stats.Flushes != flushes || stats.Mutations != flushed {
+		t.Error("Bulk writer totals do not match the reported flushes: ", stats, flushes, flushed)
+	}
+	if stats.Flushes < columns/100 {
+		t.Error("Bulk writer did not flush by mutation count: ", stats.Flushes)
+	}
+
+	for _, key := range []string{"bulk0", fmt.Sprint("bulk", rows-1)} {
+		exists, err := cp.Reader().Cf("AllTypes").Exists([]byte(key))
+		if err != nil {
+			t.Fatal("Error reading bulk written row:", err)
+		}
+		if !exists {
+			t.Error("Bulk written row ", key, " was not found")
+		}
+	}
+
+	// repeated writes of the same columns are merged by the writer and count once
+	b = cp.BulkWriter(BulkOptions{})
+	b.Insert("AllTypes", buildAllTypesRow("bulk0"))
+	b.Insert("AllTypes", buildAllTypesRow("bulk0"))
+	stats, err = b.Close()
+	if err != nil {
+		t.Error("Error closing bulk writer:", err)
+	}
+	if stats.Mutations != len(buildAllTypesRow("bulk0").Columns) {
+		t.Error("Bulk writer counted ", stats.Mutations, " mutations for repeated writes")
+	}
+
+	b = cp.BulkWriter(BulkOptions{})
+	for i := 0; i < rows; i++ {
+		b.Delete("AllTypes", []byte(fmt.Sprint("bulk", i)))
+	}
+	b.Delete("Counters", []byte("bulk0"))
+	if err = b.Flush(); err != nil {
+		t.Error("Error flushing bulk deletions:", err)
+	}
+	stats, err = b.Close()
+	if err != nil {
+		t.Error("Error closing bulk writer:", err)
+	}
+	if stats.Mutations != rows+1 || stats.Flushes != 1 {
+		t.Error("Unexpected bulk deletion totals: ", stats)
+	}
+}
@@ -0,0 +1,596 @@
+package gossie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Serialization of keyspace definitions to the statements understood by cassandra-cli, and parsing
+	of them back. Only the create statements are parsed, use and drop statements are skipped.
+*/
+
+// CliScript returns the cassandra-cli statements that create the keyspace and the column families
+// of the schema, in the same format as schema-test.txt. Type classes are written as they are in the
+// definition, and attributes with zero values are omitted so Cassandra uses its defaults for them.
+// The column family ids are not written.
+func (s *Schema) CliScript() string {
+	return cliScript(s.Definition)
+}
+
+// ParseCliScript parses the create keyspace and create column family statements of a
+// cassandra-cli script into a Schema. The column families are added to the last created or used
+// keyspace.
+func ParseCliScript(script string) (*Schema, error) {
+	d, err := parseCliScript(script)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(d), nil
+}
+
+func cliScript(d *KeyspaceDefinition) string {
+	b := new(bytes.Buffer)
+
+	fmt.Fprintf(b, "create keyspace %s", cliString(d.Name))
+	a := &cliAttributes{b: b, indent: "  "}
+	a.add("placement_strategy", cliString(d.StrategyClass), d.StrategyClass != "")
+	a.add("strategy_options", cliMap(d.StrategyOptions), len(d.StrategyOptions) > 0)
+	a.add("replication_factor", strconv.Itoa(d.ReplicationFactor), d.ReplicationFactor != 0)
+	a.add("durable_writes", strconv.FormatBool(d.DurableWrites), true)
+	b.WriteString(";\n\n")
+
+	fmt.Fprintf(b, "use %s;\n", cliString(d.Name))
+
+	for _, cf := range d.ColumnFamilies {
+		b.WriteString("\n")
+		fmt.Fprintf(b, "create column family %s", cliString(cf.Name))
+		a := &cliAttributes{b: b, indent: "\t"}
+		a.add("column_type", "Super", cf.Super)
+		a.add("comparator", cliString(cf.Comparator), cf.Comparator != "")
+		a.add("subcomparator", cliString(cf.SubComparator), cf.SubComparator != "")
+		a.add("key_validation_class", cliString(cf.KeyValidationClass), cf.KeyValidationClass != "")
+		a.add("default_validation_class", cliString(cf.DefaultValidationClass), cf.DefaultValidationClass != "")
+		a.add("key_alias", cliString(string(cf.KeyAlias)), len(cf.KeyAlias) > 0)
+		a.add("comment", cliString(cf.Comment), cf.Comment != "")
+		a.add("gc_grace", strconv.Itoa(cf.GcGraceSeconds), cf.GcGraceSeconds != 0)
+		a.add("read_repair_chance", cliFloat(cf.ReadRepairChance), cf.ReadRepairChance != 0)
+		a.add("replicate_on_write", strconv.FormatBool(cf.ReplicateOnWrite), cf.ReplicateOnWrite)
+		a.add("merge_shards_chance", cliFloat(cf.MergeShardsChance), cf.MergeShardsChance != 0)
+		a.add("rows_cached", cliFloat(cf.RowCacheSize), cf.RowCacheSize != 0)
+		a.add("row_cache_save_period", strconv.Itoa(cf.RowCacheSavePeriodInSeconds), cf.RowCacheSavePeriodInSeconds != 0)
+		a.add("row_cache_keys_to_save", strconv.Itoa(cf.RowCacheKeysToSave), cf.RowCacheKeysToSave != 0)
+		a.add("row_cache_provider", cliString(cf.RowCacheProvider), cf.RowCacheProvider != "")
+		a.add("keys_cached", cliFloat(cf.KeyCacheSize), cf.KeyCacheSize != 0)
+		a.add("key_cache_save_period", strconv.Itoa(cf.KeyCacheSavePeriodInSeconds), cf.KeyCacheSavePeriodInSeconds != 0)
+		a.add("min_compaction_threshold", strconv.Itoa(cf.MinCompactionThreshold), cf.MinCompactionThreshold != 0)
+		a.add("max_compaction_threshold", strconv.Itoa(cf.MaxCompactionThreshold), cf.MaxCompactionThreshold != 0)
+		a.add("compaction_strategy", cliString(cf.CompactionStrategy), cf.CompactionStrategy != "")
+		a.add("compaction_strategy_options", cliMap(cf.CompactionStrategyOptions), len(cf.CompactionStrategyOptions) > 0)
+		a.add("compression_options", cliMap(cf.CompressionOptions), len(cf.CompressionOptions) > 0)
+		a.add("bloom_filter_fp_chance", cliFloat(cf.BloomFilterFpChance), cf.BloomFilterFpChance != 0)
+		if len(cf.Columns) > 0 {
+			comparator := cf.Comparator
+			if cf.Super {
+				comparator = cf.SubComparator
+			}
+			columns := new(bytes.Buffer)
+			columns.WriteString("[\n")
+			for i, c := range cf.Columns {
+				fmt.Fprintf(columns, "\t\t{column_name: %s, validation_class: %s", cliName(c.Name, comparator), cliString(c.ValidationClass))
+				if c.IndexType != NoIndex {
+					fmt.Fprintf(columns, ", index_type: %s", c.IndexType)
+				}
+				if c.IndexName != "" {
+					fmt.Fprintf(columns, ", index_name: %s", cliString(c.IndexName))
+				}
+				if len(c.IndexOptions) > 0 {
+					fmt.Fprintf(columns, ", index_options: %s", cliMap(c.IndexOptions))
+				}
+				columns.WriteString("}")
+				if i < len(cf.Columns)-1 {
+					columns.WriteString(",")
+				}
+				columns.WriteString("\n")
+			}
+			columns.WriteString("\t]")
+			a.add("column_metadata", columns.String(), true)
+		}
+		b.WriteString("\n;\n")
+	}
+
+	return b.String()
+}
+
+// cliAttributes writes the "with a = b and c = d" part of a create statement
+type cliAttributes struct {
+	b      *bytes.Buffer
+	indent string
+	n      int
+}
+
+func (a *cliAttributes) add(name, value string, set bool) {
+	if !set {
+		return
+	}
+	if a.n == 0 {
+		a.b.WriteString(" with\n")
+	} else {
+		a.b.WriteString(" and\n")
+	}
+	fmt.Fprintf(a.b, "%s%s = %s", a.indent, name, value)
+	a.n++
+}
+
+// cliString quotes s unless it is a plain word
+func cliString(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool { return !isCliWordRune(r) }) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(strings.Replace(s, "\\", "\\\\", -1), "'", "\\'", -1) + "'"
+}
+
+func cliFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func cliMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := new(bytes.Buffer)
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%s: %s", cliString(k), cliString(m[k]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// cliName writes a column name the way cassandra-cli reads it for the comparator, see Format
+func cliName(name []byte, comparator string) string {
+	return cliString(Format(name, parseTypeClass(comparator)))
+}
+
+func parseCliName(s string, comparator string) ([]byte, error) {
+	return ParseValue(s, parseTypeClass(comparator))
+}
+
+func isCliWordRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' || r == '+'
+}
+
+type cliToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeCli(script string) ([]cliToken, error) {
+	tokens := make([]cliToken, 0)
+	runes := []rune(script)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-', r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case strings.ContainsRune("=;:,{}[]", r):
+			tokens = append(tokens, cliToken{text: string(r)})
+			i++
+		case r == '\'':
+			s := new(bytes.Buffer)
+			i++
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				s.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("Unterminated string in cassandra-cli script")
+			}
+			i++
+			tokens = append(tokens, cliToken{text: s.String(), quoted: true})
+		case isCliWordRune(r):
+			start := i
+			for i < len(runes) && isCliWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, cliToken{text: string(runes[start:i])})
+		default:
+			return nil, errors.New(fmt.Sprint("Unexpected character ", string(r), " in cassandra-cli script"))
+		}
+	}
+	return tokens, nil
+}
+
+type cliParser struct {
+	tokens []cliToken
+	pos    int
+}
+
+func (p *cliParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *cliParser) next() (cliToken, error) {
+	if p.done() {
+		return cliToken{}, errors.New("Unexpected end of cassandra-cli script")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+// peek checks if the next token is the passed unquoted keyword or symbol
+func (p *cliParser) peek(text string) bool {
+	return !p.done() && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, text)
+}
+
+func (p *cliParser) expect(texts ...string) error {
+	for _, text := range texts {
+		if !p.peek(text) {
+			if p.done() {
+				return errors.New(fmt.Sprint("Expected ", text, " but the cassandra-cli script ended"))
+			}
+			return errors.New(fmt.Sprint("Expected ", text, " but got ", p.tokens[p.pos].text))
+		}
+		p.pos++
+	}
+	return nil
+}
+
+func (p *cliParser) skipStatement() {
+	for !p.done() && !p.peek(";") {
+		p.pos++
+	}
+	p.pos++
+}
+
+// attributes parses the optional "with a = b and c = d" part of a create statement and the ending ;
+func (p *cliParser) attributes() (map[string]interface{}, error) {
+	attrs := make(map[string]interface{})
+	if p.peek("with") {
+		p.pos++
+		for {
+			name, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			attrs[strings.ToLower(name.text)] = value
+			if !p.peek("and") {
+				break
+			}
+			p.pos++
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// value parses a string, a {key: value} map or a [] list of values
+func (p *cliParser) value() (interface{}, error) {
+	switch {
+	case p.peek("{"):
+		p.pos++
+		m := make(map[string]interface{})
+		for !p.peek("}") {
+			k, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			m[k.text] = v
+			if p.peek(",") {
+				p.pos++
+			}
+		}
+		p.pos++
+		return m, nil
+	case p.peek("["):
+		p.pos++
+		l := make([]interface{}, 0)
+		for !p.peek("]") {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			if p.peek(",") {
+				p.pos++
+			}
+		}
+		p.pos++
+		return l, nil
+	}
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	return t.text, nil
+}
+
+func parseCliScript(script string) (*KeyspaceDefinition, error) {
+	tokens, err := tokenizeCli(script)
+	if err != nil {
+		return nil, err
+	}
+	p := &cliParser{tokens: tokens}
+	d := &KeyspaceDefinition{ColumnFamilies: make([]*ColumnFamilyDefinition, 0)}
+
+	for !p.done() {
+		switch {
+		case p.peek("create"):
+			p.pos++
+			switch {
+			case p.peek("keyspace"):
+				p.pos++
+				name, err := p.next()
+				if err != nil {
+					return nil, err
+				}
+				attrs, err := p.attributes()
+				if err != nil {
+					return nil, err
+				}
+				d.Name = name.text
+				if err = keyspaceFromCliAttributes(d, attrs); err != nil {
+					return nil, err
+				}
+			case p.peek("column"):
+				if err = p.expect("column", "family"); err != nil {
+					return nil, err
+				}
+				name, err := p.next()
+				if err != nil {
+					return nil, err
+				}
+				attrs, err := p.attributes()
+				if err != nil {
+					return nil, err
+				}
+				cf, err := columnFamilyFromCliAttributes(name.text, attrs)
+				if err != nil {
+					return nil, err
+				}
+				d.ColumnFamilies = append(d.ColumnFamilies, cf)
+			default:
+				return nil, errors.New("Expected keyspace or column family after create")
+			}
+		case p.peek("use"):
+			p.pos++
+			name, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if d.Name == "" {
+				d.Name = name.text
+			}
+			p.skipStatement()
+		case p.peek("drop"):
+			p.skipStatement()
+		default:
+			return nil, errors.New(fmt.Sprint("Unsupported cassandra-cli statement starting with ", p.tokens[p.pos].text))
+		}
+	}
+
+	return d, nil
+}
+
+// cliAttributeParser converts the attribute values of a statement to their Go types, keeping the
+// first error found
+type cliAttributeParser struct {
+	err error
+}
+
+func (a *cliAttributeParser) fail(name string, err error) {
+	if a.err == nil {
+		a.err = errors.New(fmt.Sprint("Invalid value for ", name, ": ", err))
+	}
+}
+
+func (a *cliAttributeParser) string(name string, v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		a.fail(name, errors.New("expected a single value"))
+	}
+	return s
+}
+
+func (a *cliAttributeParser) int(name string, v interface{}) int {
+	i, err := strconv.Atoi(a.string(name, v))
+	if err != nil {
+		a.fail(name, err)
+	}
+	return i
+}
+
+func (a *cliAttributeParser) float(name string, v interface{}) float64 {
+	f, err := strconv.ParseFloat(a.string(name, v), 64)
+	if err != nil {
+		a.fail(name, err)
+	}
+	return f
+}
+
+func (a *cliAttributeParser) bool(name string, v interface{}) bool {
+	b, err := strconv.ParseBool(a.string(name, v))
+	if err != nil {
+		a.fail(name, err)
+	}
+	return b
+}
+
+func (a *cliAttributeParser) strings(name string, v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		a.fail(name, errors.New("expected a map"))
+		return nil
+	}
+	r := make(map[string]string)
+	for k, mv := range m {
+		r[k] = a.string(name, mv)
+	}
+	return r
+}
+
+func keyspaceFromCliAttributes(d *KeyspaceDefinition, attrs map[string]interface{}) error {
+	a := &cliAttributeParser{}
+	for name, v := range attrs {
+		switch name {
+		case "placement_strategy":
+			d.StrategyClass = a.string(name, v)
+		case "strategy_options":
+			d.StrategyOptions = a.strings(name, v)
+		case "replication_factor":
+			d.ReplicationFactor = a.int(name, v)
+		case "durable_writes":
+			d.DurableWrites = a.bool(name, v)
+		default:
+			return errors.New(fmt.Sprint("Unsupported keyspace attribute ", name))
+		}
+	}
+	return a.err
+}
+
+func columnFamilyFromCliAttributes(cfName string, attrs map[string]interface{}) (*ColumnFamilyDefinition, error) {
+	a := &cliAttributeParser{}
+	d := &ColumnFamilyDefinition{Name: cfName}
+	var columns []interface{}
+	for name, v := range attrs {
+		switch name {
+		case "column_type":
+			d.Super = strings.EqualFold(a.string(name, v), "Super")
+		case "comparator":
+			d.Comparator = a.string(name, v)
+		case "subcomparator":
+			d.SubComparator = a.string(name, v)
+		case "key_validation_class":
+			d.KeyValidationClass = a.string(name, v)
+		case "default_validation_class":
+			d.DefaultValidationClass = a.string(name, v)
+		case "key_alias":
+			d.KeyAlias = []byte(a.string(name, v))
+		case "comment":
+			d.Comment = a.string(name, v)
+		case "gc_grace":
+			d.GcGraceSeconds = a.int(name, v)
+		case "read_repair_chance":
+			d.ReadRepairChance = a.float(name, v)
+		case "replicate_on_write":
+			d.ReplicateOnWrite = a.bool(name, v)
+		case "merge_shards_chance":
+			d.MergeShardsChance = a.float(name, v)
+		case "rows_cached":
+			d.RowCacheSize = a.float(name, v)
+		case "row_cache_save_period":
+			d.RowCacheSavePeriodInSeconds = a.int(name, v)
+		case "row_cache_keys_to_save":
+			d.RowCacheKeysToSave = a.int(name, v)
+		case "row_cache_provider":
+			d.RowCacheProvider = a.string(name, v)
+		case "keys_cached":
+			d.KeyCacheSize = a.float(name, v)
+		case "key_cache_save_period":
+			d.KeyCacheSavePeriodInSeconds = a.int(name, v)
+		case "min_compaction_threshold":
+			d.MinCompactionThreshold = a.int(name, v)
+		case "max_compaction_threshold":
+			d.MaxCompactionThreshold = a.int(name, v)
+		case "compaction_strategy":
+			d.CompactionStrategy = a.string(name, v)
+		case "compaction_strategy_options":
+			d.CompactionStrategyOptions = a.strings(name, v)
+		case "compression_options":
+			d.CompressionOptions = a.strings(name, v)
+		case "bloom_filter_fp_chance":
+			d.BloomFilterFpChance = a.float(name, v)
+		case "column_metadata":
+			l, ok := v.([]interface{})
+			if !ok {
+				a.fail(name, errors.New("expected a list"))
+			}
+			columns = l
+		default:
+			return nil, errors.New(fmt.Sprint("Unsupported column family attribute ", name, " in ", cfName))
+		}
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	// column names depend on the comparator, so they are parsed once all the attributes are known
+	comparator := d.Comparator
+	if d.Super {
+		comparator = d.SubComparator
+	}
+	for _, cv := range columns {
+		m, ok := cv.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(fmt.Sprint("Invalid column_metadata in ", cfName))
+		}
+		c := &ColumnDefinition{}
+		for name, v := range m {
+			switch name {
+			case "column_name":
+				var err error
+				if c.Name, err = parseCliName(a.string(name, v), comparator); err != nil {
+					a.fail(name, err)
+				}
+			case "validation_class":
+				c.ValidationClass = a.string(name, v)
+			case "index_type":
+				switch strings.ToUpper(a.string(name, v)) {
+				case "KEYS", "0":
+					c.IndexType = KeysIndex
+				case "CUSTOM", "1":
+					c.IndexType = CustomIndex
+				default:
+					a.fail(name, errors.New("unknown index type"))
+				}
+			case "index_name":
+				c.IndexName = a.string(name, v)
+			case "index_options":
+				c.IndexOptions = a.strings(name, v)
+			default:
+				return nil, errors.New(fmt.Sprint("Unsupported column attribute ", name, " in ", cfName))
+			}
+		}
+		if c.Name == nil && a.err == nil {
+			return nil, errors.New(fmt.Sprint("Missing column_name in the column_metadata of ", cfName))
+		}
+		d.Columns = append(d.Columns, c)
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	return d, nil
+}
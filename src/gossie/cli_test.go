@@ -0,0 +1,190 @@
+package gossie
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseCliScript(t *testing.T) {
+	script, err := ioutil.ReadFile("../../schema-test.txt")
+	if err != nil {
+		t.Fatal("Error reading the test schema: ", err)
+	}
+	schema, err := ParseCliScript(string(script))
+	if err != nil {
+		t.Fatal("Error parsing the test schema: ", err)
+	}
+
+	d := schema.Definition
+	if d.Name != "TestGossie" || d.StrategyClass != "SimpleStrategy" || d.StrategyOptions["replication_factor"] != "1" || !d.DurableWrites {
+		t.Error("Unexpected keyspace definition: ", d)
+	}
+	if len(schema.ColumnFamilies) != 9 {
+		t.Error("Test schema must have 9 CFs but got ", len(schema.ColumnFamilies))
+	}
+
+	cf := schema.ColumnFamilies["AllTypes"]
+	if cf == nil {
+		t.Fatal("Test CF AllTypes is nil")
+	}
+	if cf.DefaultComparator.Desc != AsciiType || cf.DefaultValidator.Desc != UTF8Type || len(cf.NamedColumns) != 11 {
+		t.Error("Unexpected Test CF AllTypes: ", cf)
+	}
+	if !reflect.DeepEqual(cf.IndexedColumns(), []string{"colAsciiType", "colLongType"}) || cf.NamedColumns["colLongType"].Desc != LongType {
+		t.Error("Unexpected Test CF AllTypes columns: ", cf.Columns)
+	}
+	if cf = schema.ColumnFamilies["Super"]; cf == nil || !cf.Super || cf.SubComparator.Desc != UTF8Type {
+		t.Error("Unexpected Test CF Super: ", cf)
+	}
+	if cf = schema.ColumnFamilies["ReasonableOne"]; cf == nil || cf.Definition.Comparator != "CompositeType(LongType,AsciiType)" {
+		t.Error("Unexpected Test CF ReasonableOne: ", cf)
+	}
+
+	// writing the parsed script and parsing it again gives the same definitions
+	again, err := ParseCliScript(schema.CliScript())
+	if err != nil {
+		t.Fatal("Error parsing a written script: ", err, "\n", schema.CliScript())
+	}
+	if !reflect.DeepEqual(again.Definition, schema.Definition) {
+		t.Error("Round trip of the cassandra-cli script failed:\n", schema.CliScript(), "\n", again.CliScript())
+	}
+
+	for _, bad := range []string{
+		"create keyspace",
+		"create column family A with comparator = ;",
+		"create column family A with unknown_attribute = 1;",
+		"create column family A with gc_grace = soon;",
+		"create column family A with column_metadata = [{validation_class: UTF8Type}];",
+		"create column family A with comment = 'unterminated;",
+		"update column family A with comment = 'a';",
+	} {
+		if _, err = ParseCliScript(bad); err == nil {
+			t.Error("Expected an error parsing ", bad)
+		}
+	}
+}
+
+func TestCliScript(t *testing.T) {
+	d := &KeyspaceDefinition{
+		Name:            "Exported",
+		StrategyClass:   "org.apache.cassandra.locator.NetworkTopologyStrategy",
+		StrategyOptions: map[string]string{"DC1": "3", "DC2": "2"},
+		DurableWrites:   false,
+		ColumnFamilies: []*ColumnFamilyDefinition{
+			&ColumnFamilyDefinition{
+				Name:                      "Events",
+				Comparator:                "LongType",
+				KeyValidationClass:        "UTF8Type",
+				DefaultValidationClass:    "BytesType",
+				KeyAlias:                  []byte("id"),
+				Comment:                   "it's a comment, with \\ and 'quotes'",
+				GcGraceSeconds:            3600,
+				ReadRepairChance:          0.25,
+				ReplicateOnWrite:          true,
+				KeyCacheSize:              200000,
+				MinCompactionThreshold:    4,
+				MaxCompactionThreshold:    32,
+				CompactionStrategy:        "LeveledCompactionStrategy",
+				CompactionStrategyOptions: map[string]string{"sstable_size_in_mb": "10"},
+				CompressionOptions:        map[string]string{"sstable_compression": "SnappyCompressor"},
+				BloomFilterFpChance:       0.01,
+				Columns: []*ColumnDefinition{
+					&ColumnDefinition{Name: []byte{0, 0, 0, 0, 0, 0, 0, 42}, ValidationClass: "UTF8Type", IndexType: KeysIndex, IndexName: "answer"},
+				},
+			},
+			&ColumnFamilyDefinition{
+				Name:          "Binary",
+				Super:         true,
+				Comparator:    "UTF8Type",
+				SubComparator: "BytesType",
+				Columns: []*ColumnDefinition{
+					&ColumnDefinition{Name: []byte{0xff, 0x00}, ValidationClass: "org.apache.cassandra.db.marshal.LongType"},
+				},
+			},
+		},
+	}
+
+	schema, err := ParseCliScript(NewSchema(d).CliScript())
+	if err != nil {
+		t.Fatal("Error parsing a written script: ", err, "\n", NewSchema(d).CliScript())
+	}
+	if !reflect.DeepEqual(schema.Definition, d) {
+		t.Error("Round trip of the cassandra-cli script failed:\n", NewSchema(d).CliScript(), "\n", schema.CliScript())
+	}
+	if !schema.ColumnFamilies["Events"].Indexed(string([]byte{0, 0, 0, 0, 0, 0, 0, 42})) {
+		t.Error("Index lost in the round trip")
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	d := &KeyspaceDefinition{
+		Name:            "Exported",
+		StrategyOptions: map[string]string{"replication_factor": "1"},
+		ColumnFamilies: []*ColumnFamilyDefinition{
+			&ColumnFamilyDefinition{
+				Name:                      "Events",
+				Id:                        1001,
+				Comparator:                "CompositeType(LongType,AsciiType)",
+				ReadRepairChance:          0.1,
+				CompactionStrategyOptions: map[string]string{},
+				Columns: []*ColumnDefinition{
+					&ColumnDefinition{Name: []byte{0, 1, 2}, ValidationClass: "UTF8Type", IndexType: CustomIndex, IndexOptions: map[string]string{"a": "b"}},
+					&ColumnDefinition{Name: []byte("plain"), ValidationClass: "UTF8Type"},
+				},
+			},
+		},
+	}
+
+	b, err := json.Marshal(NewSchema(d))
+	if err != nil {
+		t.Fatal("Error writing JSON: ", err)
+	}
+	schema := &Schema{}
+	if err = json.Unmarshal(b, schema); err != nil {
+		t.Fatal("Error reading JSON: ", err)
+	}
+	if !reflect.DeepEqual(schema.Definition, d) {
+		t.Error("Round trip of the JSON schema failed: ", string(b))
+	}
+	if cf := schema.ColumnFamilies["Events"]; cf == nil || !cf.Indexed(string([]byte{0, 1, 2})) || cf.Definition.Id != 1001 {
+		t.Error("Unexpected schema read from JSON: ", cf)
+	}
+
+	if err = json.Unmarshal([]byte(`{"column_families": [{"column_metadata": [{"index_type": "HASH"}]}]}`), schema); err == nil {
+		t.Error("Expected an error reading an unknown index type")
+	}
+}
+
+func TestSchemaExport(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	live := cp.Schema()
+
+	parsed, err := ParseCliScript(live.CliScript())
+	if err != nil {
+		t.Fatal("Error parsing the script of the live schema: ", err, "\n", live.CliScript())
+	}
+	if parsed.CliScript() != live.CliScript() {
+		t.Error("Round trip of the live schema script failed:\n", live.CliScript(), "\n", parsed.CliScript())
+	}
+	if !reflect.DeepEqual(parsed.ColumnFamilies["AllTypes"].NamedColumns, live.ColumnFamilies["AllTypes"].NamedColumns) {
+		t.Error("Named columns changed in the round trip of the live schema script")
+	}
+
+	b, err := json.Marshal(live)
+	if err != nil {
+		t.Fatal("Error writing JSON: ", err)
+	}
+	schema := &Schema{}
+	if err = json.Unmarshal(b, schema); err != nil {
+		t.Fatal("Error reading JSON: ", err)
+	}
+	if !reflect.DeepEqual(schema, live) {
+		t.Error("Round trip of the live schema JSON failed: ", string(b))
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,21 +27,71 @@ type ConnectionPool interface {
 	// Keyspace returns the keyspace name this ConnectionPool is connected to
 	Keyspace() string
 
-	// Schema returns the parsed schema for the keyspace this ConnectionPool is connected to
+	// Schema returns the parsed schema for the keyspace this ConnectionPool is connected to. The
+	// returned value is never modified, a new one is returned after the schema is refreshed.
 	Schema() *Schema
 
+	// RefreshSchema reads and parses again the schema of the keyspace, replacing the one returned
+	// by Schema. It returns the differences with the previous schema, and if there are any the
+	// functions registered with OnSchemaChange are called before returning. Schema changes made
+	// through Admin refresh the schema automatically, except dropping the keyspace of the pool.
+	RefreshSchema() (*SchemaChange, error)
+
+	// OnSchemaChange registers a function to be called every time a schema refresh finds changes
+	// in the keyspace schema. The functions are called one after another from the goroutine doing
+	// the refresh, once the refresh is done, so they can use the pool, including to change or
+	// refresh the schema.
+	OnSchemaChange(func(*SchemaChange))
+
 	// Reader returns a new query builder for read operations
 	Reader() Reader
 
 	// Writer returns a new mutation builder for write operations
 	Writer() Writer
 
+	// TypedReader returns a new query builder for read operations that marshals and decodes Go
+	// values using the keyspace schema
+	TypedReader() TypedReader
+
+	// TypedWriter returns a new mutation builder for write operations that marshals Go values
+	// using the keyspace schema
+	TypedWriter() TypedWriter
+
 	// Query returns a high level interface for read operations over structs
 	Query(Mapping) Query
 
 	// Batch returns a high level interface for write operations over structs
 	Batch() Batch
 
+	// Cql runs a CQL 2 query. Every ? placeholder outside of quoted strings and comments is replaced
+	// with the matching argument written as a quoted and escaped CQL literal, see CqlResult for the
+	// results.
+	// []byte arguments, and other byte slices, are bound as quoted hex. Other slices are bound as
+	// a list for IN clauses and cannot be empty, and NaN and infinite floats are rejected.
+	// Queries are compressed with GZIP when they are longer than the CqlCompression option.
+	Cql(query string, args ...interface{}) (*CqlResult, error)
+
+	// ValidateMapping checks a mapping against the schema of its column family. It returns a
+	// *MappingError listing every mismatch: a missing column family, a wrong key type, a wrong
+	// number or type of composite components, a wrong column validator, or a sparse or compact
+	// mapping over a comparator built for the other kind.
+	ValidateMapping(Mapping) error
+
+	// EnsureColumnFamily creates the column family of a mapping, as derived by
+	// DefinitionFromMapping, if it is missing from the keyspace. If it already exists it is
+	// validated against the mapping instead, see ValidateMapping. It is meant for services
+	// bootstrapping their own column families in development and tests.
+	EnsureColumnFamily(Mapping) error
+
+	// BulkWriter returns a new auto flushing write interface for loading large amounts of data
+	BulkWriter(BulkOptions) BulkWriter
+
+	// Async returns a new interface for writes running in the background
+	Async(AsyncOptions) Async
+
+	// Admin returns an interface for the cluster description and administrative operations
+	Admin() Admin
+
 	// Close all the connections in the pool
 	Close() error
 }
@@ -56,6 +108,10 @@ type PoolOptions struct {
 	Grace            int               // if a node is blacklisted try to contact it again after Grace seconds
 	Retries          int               // retry queries for Retries times before raising an error
 	Authentication   map[string]string // if one or more keys are present, login() is called with the values from Authentication
+	Timestamps       TimestampProvider // timestamps for writes, WallClock by default
+	SchemaTimeout    int               // wait up to SchemaTimeout ms for schema agreement in schema changes
+	SchemaRefresh    int               // if set, check the schema version every SchemaRefresh seconds and refresh the schema when it changes
+	CqlCompression   int               // compress CQL queries of at least CqlCompression bytes, a negative value disables compression
 }
 
 const (
@@ -80,6 +136,8 @@ const (
 	DEFAULT_RECYCLE_JITTER    = 10
 	DEFAULT_GRACE             = 5
 	DEFAULT_RETRIES           = 5
+	DEFAULT_SCHEMA_TIMEOUT    = 10000
+	DEFAULT_CQL_COMPRESSION   = 4096
 )
 
 const (
@@ -129,6 +187,15 @@ func (o *PoolOptions) defaults() {
 	if o.Retries == 0 {
 		o.Retries = DEFAULT_RETRIES
 	}
+	if o.Timestamps == nil {
+		o.Timestamps = WallClock
+	}
+	if o.SchemaTimeout == 0 {
+		o.SchemaTimeout = DEFAULT_SCHEMA_TIMEOUT
+	}
+	if o.CqlCompression == 0 {
+		o.CqlCompression = DEFAULT_CQL_COMPRESSION
+	}
 }
 
 type nodeInfo struct {
@@ -144,9 +211,21 @@ type slot struct {
 type connectionPool struct {
 	keyspace  string
 	options   PoolOptions
-	schema    *Schema
 	nodes     []*nodeInfo
 	available chan *slot
+	// set to 1 once a server rejected a SliceRange deletion
+	noSliceDeletions int32
+
+	// current schema, replaced as a whole on refresh
+	schemaLock sync.RWMutex
+	schema     *Schema
+	// serializes refreshes and guards schemaVersion
+	refreshLock   sync.Mutex
+	schemaVersion string
+	listenersLock sync.Mutex
+	listeners     []func(*SchemaChange)
+	stop          chan bool
+	stopOnce      sync.Once
 }
 
 // NewConnectionPool creates a new connection pool for the given nodes and keyspace.
@@ -163,6 +242,7 @@ func NewConnectionPool(nodes []string, keyspace string, options PoolOptions) (Co
 		options:   options,
 		nodes:     make([]*nodeInfo, len(nodes)),
 		available: make(chan *slot, options.Size),
+		stop:      make(chan bool),
 	}
 
 	for i, n := range nodes {
@@ -173,6 +253,123 @@ func NewConnectionPool(nodes []string, keyspace string, options PoolOptions) (Co
 		cp.available <- &slot{}
 	}
 
+	ksDef, err := cp.describeKeyspace()
+	if err != nil {
+		return nil, err
+	}
+
+	cp.schema = newSchema(ksDef)
+	if cp.schema == nil {
+		return nil, ErrorSchemaNotParseable
+	}
+
+	if options.SchemaRefresh > 0 {
+		go cp.watchSchema(time.Duration(options.SchemaRefresh) * time.Second)
+	}
+
+	return cp, nil
+}
+
+func (cp *connectionPool) RefreshSchema() (*SchemaChange, error) {
+	cp.refreshLock.Lock()
+	change, err := cp.refreshSchema()
+	cp.refreshLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	cp.notifySchemaChange(change)
+	return change, nil
+}
+
+// refreshSchema replaces the schema and returns the differences with the previous one. The caller
+// must hold refreshLock, and call notifySchemaChange once it is released.
+func (cp *connectionPool) refreshSchema() (*SchemaChange, error) {
+	ksDef, err := cp.describeKeyspace()
+	if err != nil {
+		return nil, err
+	}
+	schema := newSchema(ksDef)
+	if schema == nil {
+		return nil, ErrorSchemaNotParseable
+	}
+
+	cp.schemaLock.Lock()
+	old := cp.schema
+	cp.schema = schema
+	cp.schemaLock.Unlock()
+
+	return DiffSchemas(old, schema), nil
+}
+
+// notifySchemaChange calls the listeners registered with OnSchemaChange if there are changes. It
+// must be called without holding refreshLock, so the listeners can use the pool, including to
+// change or refresh the schema.
+func (cp *connectionPool) notifySchemaChange(change *SchemaChange) {
+	if change.Empty() {
+		return
+	}
+	cp.listenersLock.Lock()
+	listeners := cp.listeners
+	cp.listenersLock.Unlock()
+	for _, f := range listeners {
+		f(change)
+	}
+}
+
+func (cp *connectionPool) OnSchemaChange(f func(*SchemaChange)) {
+	cp.listenersLock.Lock()
+	defer cp.listenersLock.Unlock()
+	// copy on write so refreshes can iterate over the listeners without holding the lock
+	listeners := make([]func(*SchemaChange), len(cp.listeners), len(cp.listeners)+1)
+	copy(listeners, cp.listeners)
+	cp.listeners = append(listeners, f)
+}
+
+// checkSchema refreshes the schema if the cluster agrees on a schema version different from the
+// one of the last check. Nothing is done while the cluster is in disagreement, since the changes
+// are still propagating.
+func (cp *connectionPool) checkSchema() error {
+	versions, err := newAdmin(cp).SchemaVersions()
+	if err != nil {
+		return err
+	}
+	version, agreed := agreedSchemaVersion(versions)
+	if !agreed {
+		return nil
+	}
+
+	cp.refreshLock.Lock()
+	if version == cp.schemaVersion {
+		cp.refreshLock.Unlock()
+		return nil
+	}
+	change, err := cp.refreshSchema()
+	if err == nil {
+		cp.schemaVersion = version
+	}
+	cp.refreshLock.Unlock()
+	if err != nil {
+		return err
+	}
+	cp.notifySchemaChange(change)
+	return nil
+}
+
+func (cp *connectionPool) watchSchema(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// errors are ignored, the check is done again in the next tick
+			cp.checkSchema()
+		case <-cp.stop:
+			return
+		}
+	}
+}
+
+func (cp *connectionPool) describeKeyspace() (*cassandra.KsDef, error) {
 	var ksDef *cassandra.KsDef
 	err := cp.run(func(c *connection) *transactionError {
 		var ire *cassandra.InvalidRequestException
@@ -193,12 +390,7 @@ func NewConnectionPool(nodes []string, keyspace string, options PoolOptions) (Co
 		return nil, ErrorKeySpaceNotFound
 	}
 
-	cp.schema = newSchema(ksDef)
-	if cp.schema == nil {
-		return nil, ErrorSchemaNotParseable
-	}
-
-	return cp, nil
+	return ksDef, nil
 }
 
 type transactionError struct {
@@ -344,6 +536,10 @@ func (cp *connectionPool) blacklist(badNode string) {
 }
 
 func (cp *connectionPool) close() (err error) {
+	cp.stopOnce.Do(func() {
+		close(cp.stop)
+	})
+
 	poolCloseTimeout := time.After(
 		time.Duration(cp.options.CloseTimeout) * time.Millisecond)
 
@@ -364,6 +560,14 @@ func (cp *connectionPool) close() (err error) {
 	return nil
 }
 
+func (cp *connectionPool) supportsSliceDeletions() bool {
+	return atomic.LoadInt32(&cp.noSliceDeletions) == 0
+}
+
+func (cp *connectionPool) unsupportedSliceDeletions() {
+	atomic.StoreInt32(&cp.noSliceDeletions, 1)
+}
+
 func (cp *connectionPool) Reader() Reader {
 	return newReader(cp, cp.options.ReadConsistency)
 }
@@ -372,12 +576,63 @@ func (cp *connectionPool) Writer() Writer {
 	return newWriter(cp, cp.options.WriteConsistency)
 }
 
+func (cp *connectionPool) TypedReader() TypedReader {
+	return newTypedReader(cp)
+}
+
+func (cp *connectionPool) TypedWriter() TypedWriter {
+	return newTypedWriter(cp)
+}
+
 func (cp *connectionPool) Query(m Mapping) Query {
 	return newQuery(cp, m)
 }
 
 func (cp *connectionPool) Batch() Batch {
-	return newBatch(cp)
+	return newBatch(cp, cp.Writer())
+}
+
+func (cp *connectionPool) ValidateMapping(m Mapping) error {
+	return validateMapping(cp.Schema(), m)
+}
+
+func (cp *connectionPool) EnsureColumnFamily(m Mapping) error {
+	d, err := DefinitionFromMapping(m)
+	if err != nil {
+		return err
+	}
+	exists := func() bool {
+		_, found := cp.Schema().ColumnFamilies[d.Name]
+		return found
+	}
+	if !exists() {
+		// the column family may have been created elsewhere
+		if _, err = cp.RefreshSchema(); err != nil {
+			return err
+		}
+	}
+	if !exists() {
+		err = newAdmin(cp).AddColumnFamily(d)
+		if err != nil {
+			// lost a race with someone else creating it
+			if _, rerr := cp.RefreshSchema(); rerr != nil || !exists() {
+				return err
+			}
+		}
+	}
+	return cp.ValidateMapping(m)
+}
+
+func (cp *connectionPool) BulkWriter(options BulkOptions) BulkWriter {
+	return newBulkWriter(cp, options)
+}
+
+func (cp *connectionPool) Async(options AsyncOptions) Async {
+	return newAsync(cp, options)
+}
+
+func (cp *connectionPool) Admin() Admin {
+	return newAdmin(cp)
 }
 
 func (cp *connectionPool) Keyspace() string {
@@ -385,6 +640,8 @@ func (cp *connectionPool) Keyspace() string {
 }
 
 func (cp *connectionPool) Schema() *Schema {
+	cp.schemaLock.RLock()
+	defer cp.schemaLock.RUnlock()
 	return cp.schema
 }
 
@@ -0,0 +1,356 @@
+package gossie
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/carloscm/gossie/src/cassandra"
+	"github.com/pomack/thrift4go/lib/go/src/thrift"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+	to do:
+	prepared statements once the Thrift bindings are updated to 1.1
+*/
+
+var (
+	ErrorCqlArguments = errors.New("The number of arguments does not match the number of ? placeholders in the CQL query")
+	ErrorCqlEmptyList = errors.New("Cannot bind an empty list as a CQL argument")
+	ErrorCqlNotFinite = errors.New("Cannot bind NaN or infinite floating point values as a CQL argument")
+)
+
+// CqlResultType is the kind of result of a CQL query
+type CqlResultType int
+
+const (
+	// CqlRows is the result of SELECT queries
+	CqlRows CqlResultType = iota
+	// CqlVoid is the result of queries that return nothing, like INSERT or CREATE
+	CqlVoid
+	// CqlInt is the result of queries that return a single number, like SELECT COUNT(*)
+	CqlInt
+)
+
+// CqlResult is the result of a CQL query. Rows are set for CqlRows results and Num for CqlInt
+// results. The types of the column names and values of the rows are those reported by Cassandra
+// for the query, with DefaultNameType and DefaultValueType applying to the columns not present in
+// NameTypes and ValueTypes.
+type CqlResult struct {
+	Type             CqlResultType
+	Rows             []*Row
+	Num              int
+	NameTypes        map[string]TypeClass
+	ValueTypes       map[string]TypeClass
+	DefaultNameType  TypeClass
+	DefaultValueType TypeClass
+}
+
+// NameType returns the type of the passed column name
+func (r *CqlResult) NameType(name []byte) TypeClass {
+	if tc, found := r.NameTypes[string(name)]; found {
+		return tc
+	}
+	return r.DefaultNameType
+}
+
+// ValueType returns the type of the values of the passed column name
+func (r *CqlResult) ValueType(name []byte) TypeClass {
+	if tc, found := r.ValueTypes[string(name)]; found {
+		return tc
+	}
+	return r.DefaultValueType
+}
+
+// TypedRows returns the rows of the result with their column names and values decoded with the
+// types reported by Cassandra, see TypedReader. The row keys are decoded with the type of the KEY
+// column when it is part of the result, or returned as []byte otherwise.
+func (r *CqlResult) TypedRows() ([]*TypedRow, error) {
+	keyType := TypeClass{Desc: BytesType}
+	if tc, found := r.ValueTypes["KEY"]; found {
+		keyType = tc
+	}
+	typedRows := make([]*TypedRow, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		key, err := decodeValue(row.Key, keyType)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error decoding row key: ", err))
+		}
+		tr := &TypedRow{Key: key, Columns: make([]*TypedColumn, 0, len(row.Columns))}
+		for _, c := range row.Columns {
+			name, err := decodeValue(c.Name, r.NameType(c.Name))
+			if err != nil {
+				return nil, errors.New(fmt.Sprint("Error decoding column name ", c.Name, ": ", err))
+			}
+			value, err := decodeValue(c.Value, r.ValueType(c.Name))
+			if err != nil {
+				return nil, errors.New(fmt.Sprint("Error decoding value of column ", c.Name, ": ", err))
+			}
+			tr.Columns = append(tr.Columns, &TypedColumn{Name: name, Value: value, Ttl: c.Ttl, Timestamp: c.Timestamp})
+		}
+		typedRows = append(typedRows, tr)
+	}
+	return typedRows, nil
+}
+
+// bindCql replaces every ? placeholder outside of quoted strings, identifiers and comments with
+// the CQL literal of the matching argument
+func bindCql(query string, args []interface{}) (string, error) {
+	b := new(bytes.Buffer)
+	var quote rune
+	used := 0
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		rest := query[i:]
+		switch {
+		case quote != 0:
+			// a doubled quote inside a string ends it and starts it again, so it needs no handling
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case strings.HasPrefix(rest, "--"), strings.HasPrefix(rest, "//"):
+			size = len(rest)
+			if end := strings.Index(rest, "\n"); end >= 0 {
+				size = end + 1
+			}
+		case strings.HasPrefix(rest, "/*"):
+			size = len(rest)
+			if end := strings.Index(rest, "*/"); end >= 0 {
+				size = end + 2
+			}
+		case r == '?':
+			if used >= len(args) {
+				return "", ErrorCqlArguments
+			}
+			literal, err := cqlLiteral(args[used])
+			if err != nil {
+				return "", errors.New(fmt.Sprint("Error binding CQL argument ", used, ": ", err))
+			}
+			b.WriteString(literal)
+			used++
+			i += size
+			continue
+		}
+		b.WriteString(query[i : i+size])
+		i += size
+	}
+	if used != len(args) {
+		return "", ErrorCqlArguments
+	}
+	return b.String(), nil
+}
+
+// cqlLiteral writes a Go value as a CQL 2 literal. Strings and the rest of values read from text
+// by Cassandra are quoted, with their quotes escaped by doubling them. Byte slices and arrays, of
+// any named type, are written as quoted hex. Other slices are written as a comma separated list,
+// for IN clauses, and cannot be empty.
+func cqlLiteral(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", ErrorUnsupportedNilMarshaling
+	case string:
+		return cqlString(v), nil
+	case []byte:
+		return cqlString(hex.EncodeToString(v)), nil
+	case bool:
+		return cqlString(strconv.FormatBool(v)), nil
+	case UUID:
+		return v.String(), nil
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano()/1e6, 10), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", ErrorCqlNotFinite
+		}
+		return strconv.FormatFloat(f, 'f', -1, rv.Type().Bits()), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "", ErrorUnsupportedNilMarshaling
+		}
+		return cqlLiteral(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			for i := range b {
+				b[i] = byte(rv.Index(i).Uint())
+			}
+			return cqlString(hex.EncodeToString(b)), nil
+		}
+		if rv.Len() <= 0 {
+			return "", ErrorCqlEmptyList
+		}
+		literals := make([]string, rv.Len())
+		for i := range literals {
+			literal, err := cqlLiteral(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			literals[i] = literal
+		}
+		return strings.Join(literals, ", "), nil
+	}
+	return "", ErrorUnsupportedMarshaling
+}
+
+func cqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// cqlKeyword returns the first keyword of a query, skipping the leading whitespace and comments
+func cqlKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n")
+		switch {
+		case strings.HasPrefix(query, "--"), strings.HasPrefix(query, "//"):
+			end := strings.Index(query, "\n")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+1:]
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+2:]
+		default:
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+			})
+			if end < 0 {
+				return query
+			}
+			return query[:end]
+		}
+	}
+}
+
+// compressCql compresses a query for the GZIP compression of execute_cql_query, which Cassandra
+// actually reads as a zlib stream
+func compressCql(query string) []byte {
+	b := new(bytes.Buffer)
+	w := zlib.NewWriter(b)
+	w.Write([]byte(query))
+	w.Close()
+	return b.Bytes()
+}
+
+// typeClassesFromTMap parses the types of a map of column names to type classes
+func typeClassesFromTMap(tm thrift.TMap) map[string]TypeClass {
+	m := make(map[string]TypeClass)
+	if tm == nil {
+		return m
+	}
+	for e := range tm.Iter() {
+		v, _ := e.Value().(string)
+		switch k := e.Key().(type) {
+		case []byte:
+			m[string(k)] = parseTypeClass(v)
+		case string:
+			m[k] = parseTypeClass(v)
+		}
+	}
+	return m
+}
+
+func cqlResultFromCassandra(r *cassandra.CqlResult) *CqlResult {
+	result := &CqlResult{
+		NameTypes:        make(map[string]TypeClass),
+		ValueTypes:       make(map[string]TypeClass),
+		DefaultNameType:  TypeClass{Desc: BytesType},
+		DefaultValueType: TypeClass{Desc: BytesType},
+	}
+	switch r.TypeA1 {
+	case cassandra.VOID:
+		result.Type = CqlVoid
+		return result
+	case cassandra.INT:
+		result.Type = CqlInt
+		result.Num = int(r.Num)
+		return result
+	}
+
+	result.Type = CqlRows
+	if s := r.Schema; s != nil {
+		if s.DefaultNameType != "" {
+			result.DefaultNameType = parseTypeClass(s.DefaultNameType)
+		}
+		if s.DefaultValueType != "" {
+			result.DefaultValueType = parseTypeClass(s.DefaultValueType)
+		}
+		result.NameTypes = typeClassesFromTMap(s.NameTypes)
+		result.ValueTypes = typeClassesFromTMap(s.ValueTypes)
+	}
+
+	result.Rows = make([]*Row, 0)
+	if r.Rows != nil {
+		for rowI := range r.Rows.Iter() {
+			cqlRow := rowI.(*cassandra.CqlRow)
+			row := &Row{Key: cqlRow.Key, Columns: make([]*Column, 0)}
+			if cqlRow.Columns != nil {
+				for colI := range cqlRow.Columns.Iter() {
+					c := colI.(*cassandra.Column)
+					row.Columns = append(row.Columns, &Column{
+						Name:      c.Name,
+						Value:     c.Value,
+						Timestamp: c.Timestamp,
+						Ttl:       c.Ttl,
+					})
+				}
+			}
+			result.Rows = append(result.Rows, row)
+		}
+	}
+	return result
+}
+
+func (cp *connectionPool) Cql(query string, args ...interface{}) (*CqlResult, error) {
+	query, err := bindCql(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := cassandra.NONE
+	queryB := []byte(query)
+	if cp.options.CqlCompression > 0 && len(queryB) >= cp.options.CqlCompression {
+		compression = cassandra.GZIP
+		queryB = compressCql(query)
+	}
+
+	var ret *cassandra.CqlResult
+	err = cp.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var ue *cassandra.UnavailableException
+		var te *cassandra.TimedOutException
+		var sde *cassandra.SchemaDisagreementException
+		var err error
+		ret, ire, ue, te, sde, err = c.client.ExecuteCqlQuery(queryB, compression)
+		if sde != nil {
+			return &transactionError{err: ErrorSchemaDisagreement}
+		}
+		return &transactionError{ire, ue, te, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cqlResultFromCassandra(ret), nil
+}
@@ -0,0 +1,263 @@
+package gossie
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cqlBlob []byte
+
+func TestBindCql(t *testing.T) {
+	u, _ := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	checks := []struct {
+		query    string
+		args     []interface{}
+		expected string
+	}{
+		{"SELECT * FROM Cf", nil, "SELECT * FROM Cf"},
+		{"SELECT * FROM Cf WHERE KEY = ?", []interface{}{"it's"}, "SELECT * FROM Cf WHERE KEY = 'it''s'"},
+		{"SELECT * FROM Cf WHERE KEY = '?' AND a = ?", []interface{}{int64(-7)}, "SELECT * FROM Cf WHERE KEY = '?' AND a = -7"},
+		{"SELECT 'it''s ?' FROM \"Odd?Cf\" WHERE KEY = ?", []interface{}{[]byte{0xca, 0xfe}}, "SELECT 'it''s ?' FROM \"Odd?Cf\" WHERE KEY = 'cafe'"},
+		{"SELECT * FROM Cf WHERE KEY IN (?)", []interface{}{[]string{"a", "b'"}}, "SELECT * FROM Cf WHERE KEY IN ('a', 'b''')"},
+		{"SELECT * FROM Cf WHERE KEY = ? AND a = ?", []interface{}{cqlBlob{0xca, 0xfe}, [2]byte{1, 2}}, "SELECT * FROM Cf WHERE KEY = 'cafe' AND a = '0102'"},
+		{"SELECT * FROM Cf WHERE KEY IN (?)", []interface{}{[]cqlBlob{{1}, {}}}, "SELECT * FROM Cf WHERE KEY IN ('01', '')"},
+		{"SELECT * FROM Cf WHERE a = ? AND b = ?", []interface{}{float32(0.1), -0.5}, "SELECT * FROM Cf WHERE a = 0.1 AND b = -0.5"},
+		{"-- key = ?\nSELECT * FROM Cf // a = ?\nWHERE /* b = ? */ KEY = ?", []interface{}{"k"}, "-- key = ?\nSELECT * FROM Cf // a = ?\nWHERE /* b = ? */ KEY = 'k'"},
+		{"SELECT * FROM Cf WHERE KEY = ? -- trailing ?", []interface{}{"k"}, "SELECT * FROM Cf WHERE KEY = 'k' -- trailing ?"},
+		{"SELECT * FROM Cf WHERE KEY = '--' AND a = ? /* unterminated ?", []interface{}{1}, "SELECT * FROM Cf WHERE KEY = '--' AND a = 1 /* unterminated ?"},
+		{"INSERT INTO Cf (KEY, a, b, c, d, e) VALUES (?, ?, ?, ?, ?, ?)",
+			[]interface{}{u, true, 1.5, uint8(3), time.Unix(1350000000, 0), &u},
+			"INSERT INTO Cf (KEY, a, b, c, d, e) VALUES (00112233-4455-6677-8899-aabbccddeeff, 'true', 1.5, 3, 1350000000000, 00112233-4455-6677-8899-aabbccddeeff)"},
+	}
+	for _, check := range checks {
+		query, err := bindCql(check.query, check.args)
+		if err != nil {
+			t.Error("Error binding ", check.query, ": ", err)
+			continue
+		}
+		if query != check.expected {
+			t.Error("Unexpected bound query ", query, ", expected ", check.expected)
+		}
+	}
+
+	if _, err := bindCql("SELECT * FROM Cf WHERE KEY = ?", nil); err != ErrorCqlArguments {
+		t.Error("Expected ErrorCqlArguments for a missing argument but got ", err)
+	}
+	if _, err := bindCql("SELECT * FROM Cf", []interface{}{1}); err != ErrorCqlArguments {
+		t.Error("Expected ErrorCqlArguments for an extra argument but got ", err)
+	}
+	if _, err := bindCql("SELECT * FROM Cf WHERE KEY = ?", []interface{}{nil}); err == nil {
+		t.Error("Expected an error binding nil")
+	}
+	if _, err := bindCql("SELECT * FROM Cf WHERE KEY = ?", []interface{}{struct{}{}}); err == nil {
+		t.Error("Expected an error binding an unsupported type")
+	}
+	for _, arg := range []interface{}{[]string{}, []interface{}{}, math.NaN(), math.Inf(1), float32(math.Inf(-1))} {
+		if _, err := bindCql("SELECT * FROM Cf WHERE KEY IN (?)", []interface{}{arg}); err == nil {
+			t.Error("Expected an error binding ", arg)
+		}
+	}
+
+	query := strings.Repeat("SELECT * FROM Cf; ", 100)
+	r, err := zlib.NewReader(bytes.NewReader(compressCql(query)))
+	if err != nil {
+		t.Fatal("Error reading compressed query: ", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != query {
+		t.Error("Compressed query does not match: ", err)
+	}
+}
+
+func TestCqlResultTypedRows(t *testing.T) {
+	value, _ := Marshal(int64(42), LongType)
+	result := &CqlResult{
+		Type:             CqlRows,
+		NameTypes:        map[string]TypeClass{"KEY": TypeClass{Desc: AsciiType}},
+		ValueTypes:       map[string]TypeClass{"KEY": TypeClass{Desc: UTF8Type}, "n": TypeClass{Desc: LongType}},
+		DefaultNameType:  TypeClass{Desc: UTF8Type},
+		DefaultValueType: TypeClass{Desc: BytesType},
+		Rows: []*Row{&Row{Key: []byte("k"), Columns: []*Column{
+			&Column{Name: []byte("KEY"), Value: []byte("k")},
+			&Column{Name: []byte("n"), Value: value, Timestamp: 10},
+			&Column{Name: []byte("other"), Value: []byte{1}},
+		}}},
+	}
+	rows, err := result.TypedRows()
+	if err != nil {
+		t.Fatal("Error decoding CQL rows: ", err)
+	}
+	expected := []*TypedRow{&TypedRow{Key: "k", Columns: []*TypedColumn{
+		&TypedColumn{Name: "KEY", Value: "k"},
+		&TypedColumn{Name: "n", Value: int64(42), Timestamp: 10},
+		&TypedColumn{Name: "other", Value: []byte{1}},
+	}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Error("Unexpected CQL rows: ", rows[0])
+	}
+}
+
+func TestCql(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout, CqlCompression: 200})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	res, err := cp.Cql("INSERT INTO AllTypes (KEY, colLongType, colAsciiType, colUTF8Type) VALUES (?, ?, ?, ?)", []byte("cql"), 42, "it's", "ünicode")
+	if err != nil {
+		t.Fatal("Error inserting with CQL: ", err)
+	}
+	if res.Type != CqlVoid {
+		t.Error("Expected a VOID result for INSERT but got ", res.Type)
+	}
+
+	res, err = cp.Cql("SELECT colLongType, colAsciiType, colUTF8Type FROM AllTypes WHERE KEY = ?", []byte("cql"))
+	if err != nil {
+		t.Fatal("Error selecting with CQL: ", err)
+	}
+	if res.Type != CqlRows || len(res.Rows) != 1 {
+		t.Fatal("Unexpected CQL result: ", res)
+	}
+	rows, err := res.TypedRows()
+	if err != nil {
+		t.Fatal("Error decoding CQL rows: ", err)
+	}
+	values := make(map[interface{}]interface{})
+	for _, c := range rows[0].Columns {
+		values[c.Name] = c.Value
+	}
+	if values["colLongType"] != int64(42) || values["colAsciiType"] != "it's" || values["colUTF8Type"] != "ünicode" {
+		t.Error("Unexpected CQL row: ", values)
+	}
+
+	// long enough to be compressed
+	res, err = cp.Cql("SELECT COUNT(*) FROM AllTypes WHERE KEY = ?"+strings.Repeat(" ", 200), []byte("cql"))
+	if err != nil {
+		t.Fatal("Error counting with CQL: ", err)
+	}
+	if res.Type != CqlInt || res.Num != 1 {
+		t.Error("Unexpected CQL count result: ", res)
+	}
+
+	if _, err = cp.Cql("SELECT * FROM NotACf"); err == nil {
+		t.Error("Expected an error selecting from an unknown column family")
+	}
+}
+
+func TestQueryCqlResult(t *testing.T) {
+	cp := &connectionPool{schema: NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{Name: "ReasonableOne", Comparator: "CompositeType(LongType,AsciiType)", KeyValidationClass: "UTF8Type", KeyAlias: []byte("username")},
+	}})}
+	m, _ := NewMapping(&ReasonableOne{})
+
+	written := []*ReasonableOne{
+		&ReasonableOne{"a", 1, 1.5, 2.5, "first"},
+		&ReasonableOne{"a", 2, 3.5, 4.5, "second"},
+		&ReasonableOne{"b", 1, 5.5, 6.5, "third"},
+	}
+	res := &CqlResult{Type: CqlRows}
+	for _, key := range []string{"a", "b"} {
+		row := &Row{Key: []byte(key), Columns: []*Column{&Column{Name: []byte("USERNAME"), Value: []byte(key)}}}
+		for _, o := range written {
+			if o.Username == key {
+				mapped, err := m.Map(o)
+				if err != nil {
+					t.Fatal("Error mapping struct: ", err)
+				}
+				row.Columns = append(row.Columns, mapped.Columns...)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	// a deleted row only has its key
+	res.Rows = append(res.Rows, &Row{Key: []byte("c"), Columns: []*Column{&Column{Name: []byte("username"), Value: []byte("c")}}})
+
+	result, err := newQuery(cp, m).cqlResult(res)
+	if err != nil {
+		t.Fatal("Error building the CQL result: ", err)
+	}
+	for _, expected := range written {
+		read := &ReasonableOne{}
+		if err = result.Next(read); err != nil {
+			t.Fatal("Result next error: ", err)
+		}
+		if !reflect.DeepEqual(read, expected) {
+			t.Error("Read ", read, " does not match ", expected)
+		}
+	}
+	if err = result.Next(&ReasonableOne{}); err != Done {
+		t.Error("Result Next is not Done: ", err)
+	}
+
+	if _, err = newQuery(cp, m).cqlResult(&CqlResult{Type: CqlVoid}); err != ErrorCqlNoRows {
+		t.Error("Expected ErrorCqlNoRows but got ", err)
+	}
+
+	// anything but a SELECT is rejected before reaching the pool
+	for _, query := range []string{
+		"UPDATE ReasonableOne SET Body = 'x' WHERE KEY = 'a'",
+		"  -- SELECT\n\tINSERT INTO ReasonableOne (KEY, Body) VALUES ('a', 'x')",
+		"/* SELECT */ DELETE FROM ReasonableOne WHERE KEY = 'a'",
+		"SELECTED",
+		"",
+	} {
+		if _, err = newQuery(cp, m).Cql(query); err != ErrorCqlNotSelect {
+			t.Error("Expected ErrorCqlNotSelect for ", query, " but got ", err)
+		}
+	}
+	for query, expected := range map[string]string{
+		"select * FROM Cf":                 "select",
+		"\n// comment\n/* more */SELECT *": "SELECT",
+		"-- only a comment":                "",
+	} {
+		if keyword := cqlKeyword(query); keyword != expected {
+			t.Error("Expected keyword ", expected, " for ", query, " but got ", keyword)
+		}
+	}
+}
+
+func TestQueryCql(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	m, _ := NewMapping(&ReasonableZero{})
+	written := &ReasonableZero{"cqluser", 1.5, -2.5, "hello"}
+	if err = cp.Batch().Delete(m, written).Insert(m, written).Run(); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	res, err := cp.Query(m).Strict(true).Cql("SELECT * FROM ReasonableZero WHERE KEY = ?", "cqluser")
+	if err != nil {
+		t.Fatal("Error running CQL query: ", err)
+	}
+	read := &ReasonableZero{}
+	if err = res.Next(read); err != nil {
+		t.Fatal("Result next error: ", err)
+	}
+	if !reflect.DeepEqual(read, written) {
+		t.Error("Read ", read, " does not match ", written)
+	}
+	if err = res.Next(read); err != Done {
+		t.Error("Result Next is not Done: ", err)
+	}
+
+	if _, err = cp.Query(m).Cql("UPDATE ReasonableZero SET Body = ? WHERE KEY = ?", []byte("bye"), "cqluser"); err != ErrorCqlNotSelect {
+		t.Error("Expected ErrorCqlNotSelect for an UPDATE but got ", err)
+	}
+	res, err = cp.Query(m).Get("cqluser")
+	if err != nil {
+		t.Fatal("Error reading: ", err)
+	}
+	if err = res.Next(read); err != nil || read.Body != "hello" {
+		t.Error("The rejected UPDATE modified the row: ", read, err)
+	}
+}
@@ -0,0 +1,259 @@
+package gossie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+	Rows as generic documents decoded with the types of the schema, for JSON dumps and fixtures.
+	A decoded row looks like this, with super_columns instead of columns for rows of super column
+	families:
+
+	{
+		"key": "username",
+		"columns": [
+			{"name": [1, "Body"], "value": "hi!", "timestamp": 1350000000000000, "ttl": 0}
+		]
+	}
+*/
+
+// Decode returns the row as a document of maps, slices and plain values, using the types of the
+// passed column family. Text, integer, boolean and floating point values are returned as such,
+// composites as a slice of their decoded components, and the rest of types as their text in the
+// same format as Format, so the document can be encoded in JSON without losing information.
+func (r *Row) Decode(cf *ColumnFamily) (map[string]interface{}, error) {
+	key, err := decodeDocumentValue(r.Key, cf.KeyValidator)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("Error decoding row key: ", err))
+	}
+	doc := map[string]interface{}{"key": key}
+
+	if len(r.SuperColumns) > 0 {
+		superColumns := make([]interface{}, 0, len(r.SuperColumns))
+		for _, sc := range r.SuperColumns {
+			name, err := decodeDocumentValue(sc.Name, cf.DefaultComparator)
+			if err != nil {
+				return nil, errors.New(fmt.Sprint("Error decoding super column name ", sc.Name, ": ", err))
+			}
+			columns, err := decodeDocumentColumns(sc.Columns, cf, cf.SubComparator)
+			if err != nil {
+				return nil, err
+			}
+			superColumns = append(superColumns, map[string]interface{}{"name": name, "columns": columns})
+		}
+		doc["super_columns"] = superColumns
+		return doc, nil
+	}
+
+	columns, err := decodeDocumentColumns(r.Columns, cf, cf.DefaultComparator)
+	if err != nil {
+		return nil, err
+	}
+	doc["columns"] = columns
+	return doc, nil
+}
+
+// JSON returns the row decoded with Decode as a JSON document
+func (r *Row) JSON(cf *ColumnFamily) ([]byte, error) {
+	doc, err := r.Decode(cf)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// EncodeRow is the inverse of Row.Decode, it builds a row from a document using the types of the
+// passed column family. Values can be passed as Go values or as their text as read by ParseValue.
+func EncodeRow(doc map[string]interface{}, cf *ColumnFamily) (*Row, error) {
+	key, err := encodeDocumentValue(doc["key"], cf.KeyValidator)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("Error encoding row key: ", err))
+	}
+	r := &Row{Key: key}
+
+	if superColumns, found := doc["super_columns"]; found {
+		list, ok := superColumns.([]interface{})
+		if !ok {
+			return nil, errors.New("Expected a list of super columns")
+		}
+		for _, item := range list {
+			sc, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("Expected a super column document")
+			}
+			name, err := encodeDocumentValue(sc["name"], cf.DefaultComparator)
+			if err != nil {
+				return nil, errors.New(fmt.Sprint("Error encoding super column name ", sc["name"], ": ", err))
+			}
+			columns, err := encodeDocumentColumns(sc["columns"], cf, cf.SubComparator)
+			if err != nil {
+				return nil, err
+			}
+			r.SuperColumns = append(r.SuperColumns, &SuperColumn{Name: name, Columns: columns})
+		}
+	}
+
+	if columns, found := doc["columns"]; found {
+		if r.Columns, err = encodeDocumentColumns(columns, cf, cf.DefaultComparator); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RowFromJSON reads a row from a JSON document in the format written by Row.JSON, for example to
+// load fixtures
+func RowFromJSON(b []byte, cf *ColumnFamily) (*Row, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	// keep the exact integers, a float64 cannot hold every LongType value
+	d.UseNumber()
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return EncodeRow(doc, cf)
+}
+
+func decodeDocumentColumns(columns []*Column, cf *ColumnFamily, comparator TypeClass) ([]interface{}, error) {
+	list := make([]interface{}, 0, len(columns))
+	for _, c := range columns {
+		name, err := decodeDocumentValue(c.Name, comparator)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error decoding column name ", c.Name, ": ", err))
+		}
+		value, err := decodeDocumentValue(c.Value, cf.valueClass(c.Name))
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error decoding value of column ", c.Name, ": ", err))
+		}
+		list = append(list, map[string]interface{}{
+			"name":      name,
+			"value":     value,
+			"timestamp": c.Timestamp,
+			"ttl":       c.Ttl,
+		})
+	}
+	return list, nil
+}
+
+func encodeDocumentColumns(columns interface{}, cf *ColumnFamily, comparator TypeClass) ([]*Column, error) {
+	list, ok := columns.([]interface{})
+	if !ok {
+		return nil, errors.New("Expected a list of columns")
+	}
+	r := make([]*Column, 0, len(list))
+	for _, item := range list {
+		doc, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Expected a column document")
+		}
+		name, err := encodeDocumentValue(doc["name"], comparator)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error encoding column name ", doc["name"], ": ", err))
+		}
+		value, err := encodeDocumentValue(doc["value"], cf.valueClass(name))
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error encoding value of column ", doc["name"], ": ", err))
+		}
+		c := &Column{Name: name, Value: value}
+		if c.Timestamp, err = documentInt(doc["timestamp"]); err != nil {
+			return nil, errors.New(fmt.Sprint("Invalid timestamp for column ", doc["name"], ": ", err))
+		}
+		ttl, err := documentInt(doc["ttl"])
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Invalid ttl for column ", doc["name"], ": ", err))
+		}
+		c.Ttl = int32(ttl)
+		r = append(r, c)
+	}
+	return r, nil
+}
+
+func decodeDocumentValue(b []byte, tc TypeClass) (interface{}, error) {
+	switch tc.Desc {
+	case CompositeType:
+		components, err := safeUnpackComposite(b)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(components))
+		for i, c := range components {
+			ct := TypeClass{Desc: BytesType}
+			if i < len(tc.Components) {
+				ct = tc.Components[i]
+			}
+			if values[i], err = decodeDocumentValue(c, ct); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case AsciiType, UTF8Type, LongType, CounterColumnType, Int32Type, BooleanType, FloatType, DoubleType:
+		return decodeValue(b, tc)
+	}
+	return formatValue(b, tc)
+}
+
+func encodeDocumentValue(v interface{}, tc TypeClass) ([]byte, error) {
+	if tc.Desc == CompositeType {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		if len(values) > len(tc.Components) {
+			return nil, errors.New(fmt.Sprint("Found ", len(values), " values for a composite of ", len(tc.Components), " components"))
+		}
+		b := make([]byte, 0)
+		for i, value := range values {
+			c, err := encodeDocumentValue(value, tc.Components[i])
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, packComposite(c, eocEquals)...)
+		}
+		return b, nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		return ParseValue(v, tc)
+	case json.Number:
+		return ParseValue(v.String(), tc)
+	case bool:
+		return ParseValue(strconv.FormatBool(v), tc)
+	case float64:
+		return ParseValue(strconv.FormatFloat(v, 'f', -1, 64), tc)
+	case float32:
+		return ParseValue(strconv.FormatFloat(float64(v), 'f', -1, 32), tc)
+	case int64:
+		return ParseValue(strconv.FormatInt(v, 10), tc)
+	case int32:
+		return ParseValue(strconv.FormatInt(int64(v), 10), tc)
+	case int:
+		return ParseValue(strconv.Itoa(v), tc)
+	case nil:
+		return nil, ErrorUnsupportedNilMarshaling
+	}
+	return nil, ErrorUnsupportedMarshaling
+}
+
+// documentInt reads an optional integer from a document, missing values are 0
+func documentInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return v.Int64()
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	}
+	return 0, errors.New(fmt.Sprint("Expected an integer but got ", v))
+}
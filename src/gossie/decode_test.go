@@ -0,0 +1,131 @@
+package gossie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowDecode(t *testing.T) {
+	schema := NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{
+			Name:                   "Events",
+			Comparator:             "CompositeType(LongType,TimeUUIDType(reversed=true),UTF8Type)",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "LongType",
+			Columns: []*ColumnDefinition{
+				&ColumnDefinition{Name: []byte("unused"), ValidationClass: "UTF8Type"},
+			},
+		},
+		&ColumnFamilyDefinition{
+			Name:                   "Documents",
+			Comparator:             "UTF8Type",
+			KeyValidationClass:     "LexicalUUIDType",
+			DefaultValidationClass: "BytesType",
+			Columns: []*ColumnDefinition{
+				&ColumnDefinition{Name: []byte("created"), ValidationClass: "DateType"},
+				&ColumnDefinition{Name: []byte("size"), ValidationClass: "IntegerType"},
+				&ColumnDefinition{Name: []byte("ratio"), ValidationClass: "FloatType"},
+			},
+		},
+		&ColumnFamilyDefinition{
+			Name:          "Super",
+			Super:         true,
+			Comparator:    "AsciiType",
+			SubComparator: "LongType",
+		},
+	}})
+
+	name, _ := ParseValue("9223372036854775807:00112233-4455-6677-8899-aabbccddeeff:a\\:b", schema.ColumnFamilies["Events"].DefaultComparator)
+	value, _ := ParseValue("-1", schema.ColumnFamilies["Events"].DefaultValidator)
+	events := &Row{Key: []byte("key"), Columns: []*Column{&Column{Name: name, Value: value, Timestamp: 1350000000000000, Ttl: 60}}}
+	checkRowDecode(t, events, schema.ColumnFamilies["Events"], map[string]interface{}{
+		"key": "key",
+		"columns": []interface{}{
+			map[string]interface{}{
+				"name":      []interface{}{int64(9223372036854775807), "00112233-4455-6677-8899-aabbccddeeff", "a:b"},
+				"value":     int64(-1),
+				"timestamp": int64(1350000000000000),
+				"ttl":       int32(60),
+			},
+		},
+	})
+
+	key, _ := ParseValue("00112233-4455-6677-8899-aabbccddeeff", schema.ColumnFamilies["Documents"].KeyValidator)
+	created, _ := ParseValue("2012-10-12", TypeClass{Desc: DateType})
+	size, _ := ParseValue("123456789012345678901234567890", TypeClass{Desc: IntegerType})
+	ratio, _ := ParseValue("0.5", TypeClass{Desc: FloatType})
+	documents := &Row{Key: key, Columns: []*Column{
+		&Column{Name: []byte("body"), Value: []byte{0xca, 0xfe}, Timestamp: 1},
+		&Column{Name: []byte("created"), Value: created, Timestamp: 2},
+		&Column{Name: []byte("ratio"), Value: ratio, Timestamp: 3},
+		&Column{Name: []byte("size"), Value: size, Timestamp: 4},
+	}}
+	checkRowDecode(t, documents, schema.ColumnFamilies["Documents"], map[string]interface{}{
+		"key": "00112233-4455-6677-8899-aabbccddeeff",
+		"columns": []interface{}{
+			map[string]interface{}{"name": "body", "value": "cafe", "timestamp": int64(1), "ttl": int32(0)},
+			map[string]interface{}{"name": "created", "value": "2012-10-12 00:00:00+0000", "timestamp": int64(2), "ttl": int32(0)},
+			map[string]interface{}{"name": "ratio", "value": float32(0.5), "timestamp": int64(3), "ttl": int32(0)},
+			map[string]interface{}{"name": "size", "value": "123456789012345678901234567890", "timestamp": int64(4), "ttl": int32(0)},
+		},
+	})
+
+	sub, _ := Marshal(int64(7), LongType)
+	super := &Row{Key: []byte{1}, SuperColumns: []*SuperColumn{
+		&SuperColumn{Name: []byte("group"), Columns: []*Column{&Column{Name: sub, Value: []byte{2}, Timestamp: 5}}},
+	}}
+	checkRowDecode(t, super, schema.ColumnFamilies["Super"], map[string]interface{}{
+		"key": "01",
+		"super_columns": []interface{}{
+			map[string]interface{}{
+				"name": "group",
+				"columns": []interface{}{
+					map[string]interface{}{"name": int64(7), "value": "02", "timestamp": int64(5), "ttl": int32(0)},
+				},
+			},
+		},
+	})
+
+	// fixtures can be written by hand with plain values or text
+	row, err := RowFromJSON([]byte(`{"key": "key", "columns": [{"name": [1, "00112233-4455-6677-8899-aabbccddeeff"], "value": "42"}]}`), schema.ColumnFamilies["Events"])
+	if err != nil {
+		t.Fatal("Error reading a JSON fixture: ", err)
+	}
+	name, _ = ParseValue("1:00112233-4455-6677-8899-aabbccddeeff", schema.ColumnFamilies["Events"].DefaultComparator)
+	value, _ = Marshal(int64(42), LongType)
+	if !reflect.DeepEqual(row, &Row{Key: []byte("key"), Columns: []*Column{&Column{Name: name, Value: value}}}) {
+		t.Error("Unexpected row read from a JSON fixture: ", row)
+	}
+
+	for _, bad := range []string{
+		`{"key": "key", "columns": [{"name": [1], "value": "x"}]}`,
+		`{"key": "key", "columns": [{"name": [1, 2, 3, 4], "value": 1}]}`,
+		`{"key": "key", "columns": [{"name": [1], "value": 1, "timestamp": "now"}]}`,
+		`{"key": "key", "columns": {}}`,
+		`{"columns": []}`,
+	} {
+		if _, err = RowFromJSON([]byte(bad), schema.ColumnFamilies["Events"]); err == nil {
+			t.Error("Expected an error reading ", bad)
+		}
+	}
+}
+
+func checkRowDecode(t *testing.T, row *Row, cf *ColumnFamily, expected map[string]interface{}) {
+	doc, err := row.Decode(cf)
+	if err != nil {
+		t.Fatal("Error decoding row: ", err)
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Error("Unexpected decoded row: ", doc)
+	}
+	if again, err := EncodeRow(doc, cf); err != nil || !reflect.DeepEqual(again, row) {
+		t.Error("Round trip of the decoded row failed: ", again, err)
+	}
+	b, err := row.JSON(cf)
+	if err != nil {
+		t.Fatal("Error writing JSON: ", err)
+	}
+	if again, err := RowFromJSON(b, cf); err != nil || !reflect.DeepEqual(again, row) {
+		t.Error("Round trip of the JSON row failed: ", string(b), err)
+	}
+}
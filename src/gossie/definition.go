@@ -0,0 +1,296 @@
+package gossie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/carloscm/gossie/src/cassandra"
+	"github.com/pomack/thrift4go/lib/go/src/thrift"
+)
+
+const (
+	DEFAULT_STRATEGY_CLASS = "SimpleStrategy"
+)
+
+// IndexType is the type of the secondary index over a column
+type IndexType int
+
+const (
+	NoIndex IndexType = iota
+	KeysIndex
+	CustomIndex
+)
+
+func (t IndexType) String() string {
+	switch t {
+	case NoIndex:
+		return ""
+	case KeysIndex:
+		return "KEYS"
+	case CustomIndex:
+		return "CUSTOM"
+	}
+	return "unknown"
+}
+
+// MarshalJSON writes the index type with the names used by Cassandra
+func (t IndexType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *IndexType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "":
+		*t = NoIndex
+	case "KEYS":
+		*t = KeysIndex
+	case "CUSTOM":
+		*t = CustomIndex
+	default:
+		return errors.New(fmt.Sprint("Unknown index type ", s))
+	}
+	return nil
+}
+
+// KeyspaceDefinition describes all the attributes of a keyspace. It is used both to create or
+// update keyspaces with Admin and to describe existing keyspaces in Schema.
+type KeyspaceDefinition struct {
+	Name              string            `json:"name"`
+	StrategyClass     string            `json:"strategy_class,omitempty"`     // replica placement strategy, DEFAULT_STRATEGY_CLASS by default
+	StrategyOptions   map[string]string `json:"strategy_options"`             // strategy options, for example "replication_factor" for SimpleStrategy
+	ReplicationFactor int               `json:"replication_factor,omitempty"` // deprecated, use StrategyOptions instead
+	DurableWrites     bool              `json:"durable_writes"`               // use the commit log for the writes in this keyspace, usually true
+
+	// ColumnFamilies are created together with the keyspace by AddKeyspace. They are ignored by
+	// UpdateKeyspace, use the column family calls to update them.
+	ColumnFamilies []*ColumnFamilyDefinition `json:"column_families"`
+}
+
+// ColumnFamilyDefinition describes all the attributes of a column family. It is used both to
+// create or update column families with Admin and to describe existing column families in Schema.
+// The type classes are the same strings used by cassandra-cli, like "UTF8Type" or
+// "CompositeType(LongType,AsciiType)". Zero values are not sent to Cassandra, so the defaults of the
+// server are used for them.
+type ColumnFamilyDefinition struct {
+	Name                        string              `json:"name"`
+	Id                          int                 `json:"id,omitempty"` // assigned by Cassandra, ignored when creating column families
+	Super                       bool                `json:"super,omitempty"`
+	Comparator                  string              `json:"comparator,omitempty"`
+	SubComparator               string              `json:"subcomparator,omitempty"` // only for super column families
+	KeyValidationClass          string              `json:"key_validation_class,omitempty"`
+	DefaultValidationClass      string              `json:"default_validation_class,omitempty"`
+	KeyAlias                    []byte              `json:"key_alias"`
+	Comment                     string              `json:"comment,omitempty"`
+	GcGraceSeconds              int                 `json:"gc_grace_seconds,omitempty"`
+	ReadRepairChance            float64             `json:"read_repair_chance,omitempty"`
+	ReplicateOnWrite            bool                `json:"replicate_on_write,omitempty"`
+	MergeShardsChance           float64             `json:"merge_shards_chance,omitempty"`
+	RowCacheSize                float64             `json:"row_cache_size,omitempty"`
+	RowCacheSavePeriodInSeconds int                 `json:"row_cache_save_period_in_seconds,omitempty"`
+	RowCacheKeysToSave          int                 `json:"row_cache_keys_to_save,omitempty"`
+	RowCacheProvider            string              `json:"row_cache_provider,omitempty"`
+	KeyCacheSize                float64             `json:"key_cache_size,omitempty"`
+	KeyCacheSavePeriodInSeconds int                 `json:"key_cache_save_period_in_seconds,omitempty"`
+	MinCompactionThreshold      int                 `json:"min_compaction_threshold,omitempty"`
+	MaxCompactionThreshold      int                 `json:"max_compaction_threshold,omitempty"`
+	CompactionStrategy          string              `json:"compaction_strategy,omitempty"`
+	CompactionStrategyOptions   map[string]string   `json:"compaction_strategy_options"`
+	CompressionOptions          map[string]string   `json:"compression_options"`
+	BloomFilterFpChance         float64             `json:"bloom_filter_fp_chance,omitempty"`
+	Columns                     []*ColumnDefinition `json:"column_metadata"`
+}
+
+// ColumnDefinition describes the metadata of a named column in a ColumnFamilyDefinition
+type ColumnDefinition struct {
+	Name            []byte            `json:"name"`
+	ValidationClass string            `json:"validation_class,omitempty"`
+	IndexType       IndexType         `json:"index_type,omitempty"`
+	IndexName       string            `json:"index_name,omitempty"` // optional name for the index
+	IndexOptions    map[string]string `json:"index_options"`        // options for CustomIndex indexes
+}
+
+func tmapFromStrings(m map[string]string) thrift.TMap {
+	tm := thrift.NewTMap(thrift.STRING, thrift.STRING, len(m))
+	for k, v := range m {
+		tm.Set(k, v)
+	}
+	return tm
+}
+
+func stringsFromTMap(tm thrift.TMap) map[string]string {
+	m := make(map[string]string)
+	if tm == nil {
+		return m
+	}
+	for e := range tm.Iter() {
+		k, _ := e.Key().(string)
+		v, _ := e.Value().(string)
+		m[k] = v
+	}
+	return m
+}
+
+func (d *KeyspaceDefinition) toCassandra() *cassandra.KsDef {
+	ksDef := cassandra.NewKsDef()
+	ksDef.Name = d.Name
+	ksDef.StrategyClass = d.StrategyClass
+	if ksDef.StrategyClass == "" {
+		ksDef.StrategyClass = DEFAULT_STRATEGY_CLASS
+	}
+	ksDef.StrategyOptions = tmapFromStrings(d.StrategyOptions)
+	ksDef.ReplicationFactor = int32(d.ReplicationFactor)
+	ksDef.DurableWrites = d.DurableWrites
+	ksDef.CfDefs = thrift.NewTList(thrift.STRUCT, len(d.ColumnFamilies))
+	for _, cf := range d.ColumnFamilies {
+		ksDef.CfDefs.Push(cf.toCassandra(d.Name))
+	}
+	return ksDef
+}
+
+func (d *ColumnFamilyDefinition) toCassandra(keyspace string) *cassandra.CfDef {
+	cfDef := cassandra.NewCfDef()
+	cfDef.Keyspace = keyspace
+	cfDef.Name = d.Name
+	cfDef.Id = int32(d.Id)
+	if d.Super {
+		cfDef.ColumnType = "Super"
+		cfDef.SubcomparatorType = d.SubComparator
+	}
+	if d.Comparator != "" {
+		cfDef.ComparatorType = d.Comparator
+	}
+	cfDef.KeyValidationClass = d.KeyValidationClass
+	cfDef.DefaultValidationClass = d.DefaultValidationClass
+	cfDef.KeyAlias = d.KeyAlias
+	cfDef.Comment = d.Comment
+	cfDef.GcGraceSeconds = int32(d.GcGraceSeconds)
+	if d.ReadRepairChance != 0 {
+		cfDef.ReadRepairChance = d.ReadRepairChance
+	}
+	cfDef.ReplicateOnWrite = d.ReplicateOnWrite
+	cfDef.MergeShardsChance = d.MergeShardsChance
+	cfDef.RowCacheSize = d.RowCacheSize
+	cfDef.RowCacheSavePeriodInSeconds = int32(d.RowCacheSavePeriodInSeconds)
+	cfDef.RowCacheKeysToSave = int32(d.RowCacheKeysToSave)
+	cfDef.RowCacheProvider = d.RowCacheProvider
+	if d.KeyCacheSize != 0 {
+		cfDef.KeyCacheSize = d.KeyCacheSize
+	}
+	cfDef.KeyCacheSavePeriodInSeconds = int32(d.KeyCacheSavePeriodInSeconds)
+	cfDef.MinCompactionThreshold = int32(d.MinCompactionThreshold)
+	cfDef.MaxCompactionThreshold = int32(d.MaxCompactionThreshold)
+	cfDef.CompactionStrategy = d.CompactionStrategy
+	cfDef.CompactionStrategyOptions = tmapFromStrings(d.CompactionStrategyOptions)
+	cfDef.CompressionOptions = tmapFromStrings(d.CompressionOptions)
+	cfDef.BloomFilterFpChance = d.BloomFilterFpChance
+	cfDef.ColumnMetadata = thrift.NewTList(thrift.STRUCT, len(d.Columns))
+	for _, c := range d.Columns {
+		cfDef.ColumnMetadata.Push(c.toCassandra())
+	}
+	return cfDef
+}
+
+func (d *ColumnDefinition) toCassandra() *cassandra.ColumnDef {
+	colDef := cassandra.NewColumnDef()
+	colDef.Name = d.Name
+	colDef.ValidationClass = d.ValidationClass
+	if colDef.ValidationClass == "" {
+		colDef.ValidationClass = "BytesType"
+	}
+	switch d.IndexType {
+	case KeysIndex:
+		colDef.IndexType = cassandra.KEYS
+	case CustomIndex:
+		colDef.IndexType = cassandra.CUSTOM
+	}
+	if d.IndexType != NoIndex {
+		colDef.IndexName = d.IndexName
+		colDef.IndexOptions = tmapFromStrings(d.IndexOptions)
+	}
+	return colDef
+}
+
+func keyspaceDefinitionFromCassandra(ksDef *cassandra.KsDef) *KeyspaceDefinition {
+	d := &KeyspaceDefinition{
+		Name:              ksDef.Name,
+		StrategyClass:     ksDef.StrategyClass,
+		StrategyOptions:   stringsFromTMap(ksDef.StrategyOptions),
+		ReplicationFactor: int(ksDef.ReplicationFactor),
+		DurableWrites:     ksDef.DurableWrites,
+		ColumnFamilies:    make([]*ColumnFamilyDefinition, 0),
+	}
+	if ksDef.CfDefs != nil {
+		for cfDefT := range ksDef.CfDefs.Iter() {
+			// FIXME: this is weird, but happens a lot. thrift4go problem?
+			if cfDefT == nil {
+				continue
+			}
+			d.ColumnFamilies = append(d.ColumnFamilies, columnFamilyDefinitionFromCassandra(cfDefT.(*cassandra.CfDef)))
+		}
+	}
+	return d
+}
+
+func columnFamilyDefinitionFromCassandra(cfDef *cassandra.CfDef) *ColumnFamilyDefinition {
+	d := &ColumnFamilyDefinition{
+		Name:                        cfDef.Name,
+		Id:                          int(cfDef.Id),
+		Super:                       cfDef.ColumnType == "Super",
+		Comparator:                  cfDef.ComparatorType,
+		SubComparator:               cfDef.SubcomparatorType,
+		KeyValidationClass:          cfDef.KeyValidationClass,
+		DefaultValidationClass:      cfDef.DefaultValidationClass,
+		KeyAlias:                    cfDef.KeyAlias,
+		Comment:                     cfDef.Comment,
+		GcGraceSeconds:              int(cfDef.GcGraceSeconds),
+		ReadRepairChance:            cfDef.ReadRepairChance,
+		ReplicateOnWrite:            cfDef.ReplicateOnWrite,
+		MergeShardsChance:           cfDef.MergeShardsChance,
+		RowCacheSize:                cfDef.RowCacheSize,
+		RowCacheSavePeriodInSeconds: int(cfDef.RowCacheSavePeriodInSeconds),
+		RowCacheKeysToSave:          int(cfDef.RowCacheKeysToSave),
+		RowCacheProvider:            cfDef.RowCacheProvider,
+		KeyCacheSize:                cfDef.KeyCacheSize,
+		KeyCacheSavePeriodInSeconds: int(cfDef.KeyCacheSavePeriodInSeconds),
+		MinCompactionThreshold:      int(cfDef.MinCompactionThreshold),
+		MaxCompactionThreshold:      int(cfDef.MaxCompactionThreshold),
+		CompactionStrategy:          cfDef.CompactionStrategy,
+		CompactionStrategyOptions:   stringsFromTMap(cfDef.CompactionStrategyOptions),
+		CompressionOptions:          stringsFromTMap(cfDef.CompressionOptions),
+		BloomFilterFpChance:         cfDef.BloomFilterFpChance,
+		Columns:                     make([]*ColumnDefinition, 0),
+	}
+	if cfDef.ColumnMetadata != nil {
+		for colDefT := range cfDef.ColumnMetadata.Iter() {
+			// FIXME: this is weird, but happens a lot. thrift4go problem?
+			if colDefT == nil {
+				continue
+			}
+			d.Columns = append(d.Columns, columnDefinitionFromCassandra(colDefT.(*cassandra.ColumnDef)))
+		}
+	}
+	return d
+}
+
+func columnDefinitionFromCassandra(colDef *cassandra.ColumnDef) *ColumnDefinition {
+	d := &ColumnDefinition{
+		Name:            colDef.Name,
+		ValidationClass: colDef.ValidationClass,
+		IndexOptions:    make(map[string]string),
+	}
+	if colDef.IsSetIndexType() {
+		switch colDef.IndexType {
+		case cassandra.KEYS:
+			d.IndexType = KeysIndex
+		case cassandra.CUSTOM:
+			d.IndexType = CustomIndex
+		}
+		d.IndexName = colDef.IndexName
+		d.IndexOptions = stringsFromTMap(colDef.IndexOptions)
+	}
+	return d
+}
@@ -0,0 +1,337 @@
+package gossie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Human readable text for Cassandra values, using the same literals as cassandra-cli: hex for
+	bytes, decimal numbers, dashed UUIDs, dates and composite components separated by colons.
+*/
+
+// dateLayouts are the date formats accepted by DateType in cassandra-cli, without a zone they are
+// read as UTC
+var dateLayouts = []string{
+	"2006-01-02 15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04-0700",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04-0700",
+	"2006-01-02T15:04",
+	"2006-01-02-0700",
+	"2006-01-02",
+}
+
+// Format returns the value in b as readable text for the passed type. Composite components are
+// formatted with the type of each component and joined with colons, escaping colons and
+// backslashes inside them with a backslash. Values that cannot be decoded with the type, like a
+// malformed column written with a different type, are returned in hex.
+func Format(b []byte, tc TypeClass) string {
+	s, err := formatValue(b, tc)
+	if err != nil {
+		return hex.EncodeToString(b)
+	}
+	return s
+}
+
+func formatValue(b []byte, tc TypeClass) (string, error) {
+	switch tc.Desc {
+	case CompositeType:
+		components, err := safeUnpackComposite(b)
+		if err != nil {
+			return "", err
+		}
+		parts := make([]string, len(components))
+		for i, c := range components {
+			ct := TypeClass{Desc: BytesType}
+			if i < len(tc.Components) {
+				ct = tc.Components[i]
+			}
+			part, err := formatValue(c, ct)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = strings.Replace(strings.Replace(part, "\\", "\\\\", -1), ":", "\\:", -1)
+		}
+		return strings.Join(parts, ":"), nil
+	case IntegerType:
+		if len(b) == 0 {
+			return "", ErrorCassandraTypeSerializationUnmarshaling
+		}
+		return unmarshalVarint(b).String(), nil
+	case DecimalType:
+		if len(b) < 5 {
+			return "", ErrorCassandraTypeSerializationUnmarshaling
+		}
+		var scale int32
+		if err := Unmarshal(b[:4], Int32Type, &scale); err != nil {
+			return "", err
+		}
+		return formatDecimal(unmarshalVarint(b[4:]), int(scale)), nil
+	case LongType, CounterColumnType:
+		if len(b) != 8 {
+			return "", ErrorCassandraTypeSerializationUnmarshaling
+		}
+	case Int32Type:
+		if len(b) != 4 {
+			return "", ErrorCassandraTypeSerializationUnmarshaling
+		}
+	case DateType:
+		if len(b) != 8 {
+			return "", ErrorCassandraTypeSerializationUnmarshaling
+		}
+		var t time.Time
+		if err := Unmarshal(b, DateType, &t); err != nil {
+			return "", err
+		}
+		// whole seconds are written as dates, anything else as the exact timestamp in ms
+		if t.Nanosecond() != 0 {
+			return strconv.FormatInt(t.UnixNano()/1e6, 10), nil
+		}
+		return t.UTC().Format(dateLayouts[0]), nil
+	}
+
+	v, err := decodeValue(b, tc)
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case []byte:
+		return hex.EncodeToString(v), nil
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case UUID:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return "", ErrorUnsupportedCassandraTypeUnmarshaling
+}
+
+// ParseValue is the inverse of Format, it reads the text of a value of the passed type and returns
+// it marshaled. Dates are read as a timestamp in ms or in any of the formats of cassandra-cli.
+func ParseValue(s string, tc TypeClass) ([]byte, error) {
+	switch tc.Desc {
+	case CompositeType:
+		parts := splitComposite(s)
+		if len(parts) > len(tc.Components) {
+			return nil, errors.New(fmt.Sprint("Found ", len(parts), " values for a composite of ", len(tc.Components), " components in ", s))
+		}
+		b := make([]byte, 0)
+		for i, part := range parts {
+			c, err := ParseValue(part, tc.Components[i])
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, packComposite(c, eocEquals)...)
+		}
+		return b, nil
+	case AsciiType, UTF8Type:
+		return []byte(s), nil
+	case LongType, CounterColumnType:
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(v, LongType)
+	case Int32Type:
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(int32(v), Int32Type)
+	case IntegerType:
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, errors.New(fmt.Sprint("Invalid integer ", s))
+		}
+		return marshalVarint(v), nil
+	case DecimalType:
+		unscaled, scale, err := parseDecimal(s)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := Marshal(int32(scale), Int32Type)
+		return append(b, marshalVarint(unscaled)...), nil
+	case UUIDType, TimeUUIDType, LexicalUUIDType:
+		v, err := ParseUUID(strings.ToLower(s))
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Invalid UUID ", s))
+		}
+		return Marshal(v, tc.Desc)
+	case BooleanType:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(v, BooleanType)
+	case FloatType:
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(float32(v), FloatType)
+	case DoubleType:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(v, DoubleType)
+	case DateType:
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return Marshal(ms, LongType)
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return Marshal(t, DateType)
+			}
+		}
+		return nil, errors.New(fmt.Sprint("Invalid date ", s))
+	}
+	return hex.DecodeString(s)
+}
+
+// splitComposite splits the text of a composite at the colons not escaped with a backslash, and
+// removes the escaping from the parts
+func splitComposite(s string) []string {
+	parts := make([]string, 0)
+	part := new(bytes.Buffer)
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			part.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// safeUnpackComposite works like unpackComposite but it returns an error for malformed composites
+func safeUnpackComposite(b []byte) ([][]byte, error) {
+	for rest := b; len(rest) > 0; {
+		if len(rest) < 3 {
+			return nil, ErrorCassandraTypeSerializationUnmarshaling
+		}
+		l := int(rest[0])<<8 | int(rest[1])
+		if len(rest) < l+3 {
+			return nil, ErrorCassandraTypeSerializationUnmarshaling
+		}
+		rest = rest[l+3:]
+	}
+	return unpackComposite(b), nil
+}
+
+// unmarshalVarint reads the big endian two's complement integers of IntegerType
+func unmarshalVarint(b []byte) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return v
+}
+
+// marshalVarint writes v with the minimal number of bytes as IntegerType does
+func marshalVarint(v *big.Int) []byte {
+	if v.Sign() >= 0 {
+		b := v.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// -v-1 has the same bits as v inverted, which need one more bit for the sign
+	n := new(big.Int).Neg(v)
+	n.Sub(n, big.NewInt(1))
+	size := n.BitLen()/8 + 1
+	u := new(big.Int).Add(v, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	b := u.Bytes()
+	for len(b) < size {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// formatDecimal writes unscaled * 10^-scale as a decimal number
+func formatDecimal(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return new(big.Int).Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil)).String()
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	for len(digits) <= scale {
+		digits = "0" + digits
+	}
+	s := digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// parseDecimal reads a decimal number into its unscaled value and its scale
+func parseDecimal(s string) (*big.Int, int, error) {
+	digits := s
+	scale := 0
+	if dot := strings.Index(s, "."); dot >= 0 {
+		digits = s[:dot] + s[dot+1:]
+		scale = len(s) - dot - 1
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, 0, errors.New(fmt.Sprint("Invalid decimal ", s))
+	}
+	return unscaled, scale, nil
+}
+
+// Format returns the row as readable text with the types of the passed column family, in the same
+// layout cassandra-cli uses to list rows
+func (r *Row) Format(cf *ColumnFamily) string {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "RowKey: %s\n", Format(r.Key, cf.KeyValidator))
+	columnComparator := cf.DefaultComparator
+	if cf.Super {
+		columnComparator = cf.SubComparator
+	}
+	formatColumn := func(c *Column) string {
+		s := fmt.Sprintf("(column=%s, value=%s, timestamp=%d", Format(c.Name, columnComparator), Format(c.Value, cf.valueClass(c.Name)), c.Timestamp)
+		if c.Ttl > 0 {
+			s += fmt.Sprintf(", ttl=%d", c.Ttl)
+		}
+		return s + ")"
+	}
+	for _, c := range r.Columns {
+		fmt.Fprintf(b, "=> %s\n", formatColumn(c))
+	}
+	for _, sc := range r.SuperColumns {
+		fmt.Fprintf(b, "=> (super_column=%s,", Format(sc.Name, cf.DefaultComparator))
+		for _, c := range sc.Columns {
+			fmt.Fprintf(b, "\n     %s", formatColumn(c))
+		}
+		b.WriteString(")\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package gossie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	checks := []struct {
+		text     string
+		tc       TypeClass
+		expected []byte
+	}{
+		{"0aff", TypeClass{Desc: BytesType}, []byte{0x0a, 0xff}},
+		{"hello: world", TypeClass{Desc: UTF8Type}, []byte("hello: world")},
+		{"-42", TypeClass{Desc: LongType}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xd6}},
+		{"42", TypeClass{Desc: CounterColumnType}, []byte{0, 0, 0, 0, 0, 0, 0, 42}},
+		{"42", TypeClass{Desc: Int32Type}, []byte{0, 0, 0, 42}},
+		{"0", TypeClass{Desc: IntegerType}, []byte{0}},
+		{"128", TypeClass{Desc: IntegerType}, []byte{0, 0x80}},
+		{"-1", TypeClass{Desc: IntegerType}, []byte{0xff}},
+		{"-128", TypeClass{Desc: IntegerType}, []byte{0x80}},
+		{"-129", TypeClass{Desc: IntegerType}, []byte{0xff, 0x7f}},
+		{"123456789012345678901234567890", TypeClass{Desc: IntegerType}, []byte{0x01, 0x8e, 0xe9, 0x0f, 0xf6, 0xc3, 0x73, 0xe0, 0xee, 0x4e, 0x3f, 0x0a, 0xd2}},
+		{"-1.25", TypeClass{Desc: DecimalType}, []byte{0, 0, 0, 2, 0x83}},
+		{"0.05", TypeClass{Desc: DecimalType}, []byte{0, 0, 0, 2, 5}},
+		{"00112233-4455-6677-8899-aabbccddeeff", TypeClass{Desc: TimeUUIDType, Reversed: true}, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}},
+		{"true", TypeClass{Desc: BooleanType}, []byte{1}},
+		{"1.5", TypeClass{Desc: FloatType}, []byte{0x3f, 0xc0, 0, 0}},
+		{"-0.1", TypeClass{Desc: DoubleType}, []byte{0xbf, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"2012-10-12 00:00:00+0000", TypeClass{Desc: DateType}, []byte{0, 0, 0x01, 0x3a, 0x52, 0x45, 0x3c, 0x00}},
+		{"1350000000001", TypeClass{Desc: DateType}, []byte{0, 0, 0x01, 0x3a, 0x52, 0x45, 0x3c, 0x01}},
+		{"7:a\\:b\\\\c", parseTypeClass("CompositeType(ReversedType(LongType),UTF8Type)"), []byte{0, 8, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 5, 'a', ':', 'b', '\\', 'c', 0}},
+		{"7", parseTypeClass("CompositeType(LongType,UTF8Type)"), []byte{0, 8, 0, 0, 0, 0, 0, 0, 0, 7, 0}},
+	}
+	for _, check := range checks {
+		b, err := ParseValue(check.text, check.tc)
+		if err != nil {
+			t.Error("Error parsing ", check.text, ": ", err)
+			continue
+		}
+		if !reflect.DeepEqual(b, check.expected) {
+			t.Errorf("Parsed %s as %x, expected %x", check.text, b, check.expected)
+		}
+		if s := Format(check.expected, check.tc); s != check.text {
+			t.Error("Formatted ", check.expected, " as ", s, ", expected ", check.text)
+		}
+	}
+
+	// other date formats are accepted too
+	for _, text := range []string{"2012-10-12", "2012-10-12T00:00", "2012-10-12 02:00:00+0200", "1350000000000"} {
+		if b, err := ParseValue(text, TypeClass{Desc: DateType}); err != nil || Format(b, TypeClass{Desc: DateType}) != "2012-10-12 00:00:00+0000" {
+			t.Error("Unexpected date parsing ", text, ": ", Format(b, TypeClass{Desc: DateType}), " ", err)
+		}
+	}
+
+	// values that do not decode with the type are written in hex
+	if s := Format([]byte{1, 2}, TypeClass{Desc: LongType}); s != "0102" {
+		t.Error("Expected hex for a malformed long but got ", s)
+	}
+	if s := Format([]byte{0, 9, 1}, parseTypeClass("CompositeType(UTF8Type)")); s != "000901" {
+		t.Error("Expected hex for a malformed composite but got ", s)
+	}
+
+	for _, bad := range []struct {
+		text string
+		tc   TypeClass
+	}{
+		{"x", TypeClass{Desc: LongType}},
+		{"3000000000", TypeClass{Desc: Int32Type}},
+		{"1.5", TypeClass{Desc: IntegerType}},
+		{"1.x", TypeClass{Desc: DecimalType}},
+		{"not-a-uuid", TypeClass{Desc: UUIDType}},
+		{"yesterday", TypeClass{Desc: DateType}},
+		{"zz", TypeClass{Desc: BytesType}},
+		{"1:2:3", parseTypeClass("CompositeType(LongType,LongType)")},
+	} {
+		if _, err := ParseValue(bad.text, bad.tc); err == nil {
+			t.Error("Expected an error parsing ", bad.text)
+		}
+	}
+
+	cf := NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{
+			Name:                   "Events",
+			Comparator:             "CompositeType(LongType,UTF8Type)",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "LongType",
+		},
+	}}).ColumnFamilies["Events"]
+	name, _ := ParseValue("1:count", cf.DefaultComparator)
+	value, _ := ParseValue("99", cf.DefaultValidator)
+	row := &Row{Key: []byte("key"), Columns: []*Column{&Column{Name: name, Value: value, Timestamp: 1000, Ttl: 60}}}
+	if s := row.Format(cf); s != "RowKey: key\n=> (column=1:count, value=99, timestamp=1000, ttl=60)\n" {
+		t.Error("Unexpected formatted row: ", s)
+	}
+}
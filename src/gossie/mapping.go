@@ -424,3 +424,244 @@ func (m *compactMapping) Unmap(destination interface{}, provider RowProvider) er
 
 	return nil
 }
+
+// mappingInternals returns the sparse mapping part of the mappings built by NewMapping, plus the
+// value field for compact mappings, or nil for other Mapping implementations
+func mappingInternals(m Mapping) (sm *sparseMapping, value string, compact bool) {
+	switch t := m.(type) {
+	case *sparseMapping:
+		return t, "", false
+	case *compactMapping:
+		return &t.sparseMapping, t.value, true
+	}
+	return nil, "", false
+}
+
+// MappingError lists every mismatch found between a Mapping and the schema of its column family
+type MappingError struct {
+	Cf         string
+	Mismatches []string
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprint("Mapping for column family ", e.Cf, " does not match the schema: ", strings.Join(e.Mismatches, "; "))
+}
+
+// validateMapping checks a mapping against a schema, returning a *MappingError with all the
+// mismatches found. Only the column family is checked for Mapping implementations not built by
+// NewMapping.
+func validateMapping(schema *Schema, m Mapping) error {
+	e := &MappingError{Cf: m.Cf(), Mismatches: make([]string, 0)}
+	mismatch := func(a ...interface{}) {
+		e.Mismatches = append(e.Mismatches, fmt.Sprint(a...))
+	}
+
+	cf, found := schema.ColumnFamilies[m.Cf()]
+	if !found {
+		mismatch("column family ", m.Cf(), " not found in the keyspace schema")
+		return e
+	}
+
+	sm, value, compact := mappingInternals(m)
+	if sm == nil {
+		return nil
+	}
+
+	if cf.Super {
+		mismatch("mappings do not support super column families")
+		return e
+	}
+
+	key := sm.si.goFields[sm.key]
+	if !compatibleTypes(key.cassandraType, cf.KeyValidator.Desc) {
+		mismatch("key field ", key.name, " is ", key.cassandraType, " but the key validator is ", cf.KeyValidator.Desc)
+	}
+
+	// the types the column names are made of: the components, plus the field name for sparse mappings
+	nameTypes := make([]*field, 0)
+	for _, c := range sm.components {
+		nameTypes = append(nameTypes, sm.si.goFields[c])
+	}
+	comparator := cf.DefaultComparator
+	composite := comparator.Desc == CompositeType
+
+	n := len(nameTypes)
+	if compact {
+		switch {
+		case n == 1 && !composite:
+			if !compatibleTypes(nameTypes[0].cassandraType, comparator.Desc) {
+				mismatch("component field ", nameTypes[0].name, " is ", nameTypes[0].cassandraType, " but the comparator is ", comparator.Desc)
+			}
+		case !composite:
+			mismatch("compact mapping with ", n, " components but the comparator is ", comparator.Desc, ", not a composite")
+		default:
+			if len(comparator.Components) == n+1 && isNameType(comparator.Components[n].Desc) {
+				mismatch("compact mapping with ", n, " components but the comparator has one more component for field names, use a sparse mapping")
+			} else if len(comparator.Components) != n {
+				mismatch("compact mapping with ", n, " components but the comparator has ", len(comparator.Components))
+			}
+			validateComponents(nameTypes, comparator.Components, mismatch)
+		}
+	} else {
+		switch {
+		case n == 0 && composite:
+			mismatch("sparse mapping without components but the comparator is a composite with ", len(comparator.Components), " components")
+		case n == 0:
+			if !isNameType(comparator.Desc) {
+				mismatch("sparse mapping stores the field names but the comparator is ", comparator.Desc, ", use a compact mapping")
+			}
+		case !composite:
+			mismatch("sparse mapping with ", n, " components but the comparator is ", comparator.Desc, ", not a composite")
+		default:
+			if len(comparator.Components) == n {
+				mismatch("sparse mapping with ", n, " components but the comparator has no component for field names, use a compact mapping")
+			} else if len(comparator.Components) != n+1 {
+				mismatch("sparse mapping with ", n, " components needs a comparator with ", n+1, " components but it has ", len(comparator.Components))
+			} else if !isNameType(comparator.Components[n].Desc) {
+				mismatch("the last comparator component is ", comparator.Components[n].Desc, " and cannot store field names")
+			}
+			validateComponents(nameTypes, comparator.Components, mismatch)
+		}
+	}
+
+	// column values
+	if compact {
+		if f, found := sm.si.goFields[value]; found && !compatibleTypes(f.cassandraType, cf.DefaultValidator.Desc) {
+			mismatch("value field ", f.name, " is ", f.cassandraType, " but the default validator is ", cf.DefaultValidator.Desc)
+		}
+	} else {
+		for _, f := range sm.si.orderedFields {
+			if f.name == sm.key || sm.componentsMap[f.name] {
+				continue
+			}
+			validator := cf.DefaultValidator
+			// named columns only apply to plain column names
+			if len(sm.components) == 0 {
+				if named, found := cf.NamedColumns[f.cassandraName]; found {
+					validator = named
+				}
+			}
+			if !compatibleTypes(f.cassandraType, validator.Desc) {
+				mismatch("field ", f.name, " is ", f.cassandraType, " but the validator of column ", f.cassandraName, " is ", validator.Desc)
+			}
+		}
+	}
+
+	if len(e.Mismatches) > 0 {
+		return e
+	}
+	return nil
+}
+
+// validateComponents checks the types of the components present both in the mapping and the comparator
+func validateComponents(fields []*field, components []TypeClass, mismatch func(...interface{})) {
+	for i, f := range fields {
+		if i >= len(components) {
+			break
+		}
+		if !compatibleTypes(f.cassandraType, components[i].Desc) {
+			mismatch("component field ", f.name, " is ", f.cassandraType, " but the comparator component ", i, " is ", components[i].Desc)
+		}
+	}
+}
+
+// compatibleTypes checks if values marshaled as t are accepted by the validator v
+func compatibleTypes(t, v TypeDesc) bool {
+	switch {
+	case t == v, v == BytesType:
+		return true
+	case (t == AsciiType || t == UTF8Type) && (v == AsciiType || v == UTF8Type):
+		// Go strings are marshaled the same way for both
+		return true
+	case t == LongType && v == CounterColumnType:
+		return true
+	case t == UUIDType && (v == TimeUUIDType || v == LexicalUUIDType):
+		// the default type for UUID fields
+		return true
+	}
+	return false
+}
+
+// isNameType checks if a comparator can store the field names of a sparse mapping
+func isNameType(t TypeDesc) bool {
+	return t == UTF8Type || t == AsciiType || t == BytesType
+}
+
+// DefinitionFromMapping derives the definition of a column family able to store the structs of a
+// mapping built by NewMapping. The key field type becomes the key validation class. The comparator
+// is built from the cols fields, plus an UTF8Type component for the field names in sparse mappings,
+// using a CompositeType when there is more than one component. In sparse mappings without
+// composites every field becomes a named column with its type as validation class, and fields
+// tagged with `index:"true"` get a KEYS secondary index. In any other case the value fields set the
+// default validation class, which is BytesType if they have different types. Tag all the value
+// fields with `type:"CounterColumnType"` to get a counter column family.
+func DefinitionFromMapping(m Mapping) (*ColumnFamilyDefinition, error) {
+	sm, value, compact := mappingInternals(m)
+	if sm == nil {
+		return nil, errors.New("Only mappings built by NewMapping can generate a column family definition")
+	}
+
+	d := &ColumnFamilyDefinition{
+		Name:               sm.cf,
+		KeyValidationClass: className(sm.si.goFields[sm.key].cassandraType),
+		Columns:            make([]*ColumnDefinition, 0),
+	}
+
+	components := make([]string, 0)
+	for _, c := range sm.components {
+		components = append(components, className(sm.si.goFields[c].cassandraType))
+	}
+	if !compact {
+		components = append(components, className(UTF8Type))
+	}
+	switch len(components) {
+	case 0:
+		return nil, errors.New(fmt.Sprint("Compact mapping without components in column family ", sm.cf))
+	case 1:
+		d.Comparator = components[0]
+	default:
+		d.Comparator = className(CompositeType) + "(" + strings.Join(components, ",") + ")"
+	}
+
+	// value fields
+	values := make([]*field, 0)
+	if compact {
+		if f, found := sm.si.goFields[value]; found {
+			values = append(values, f)
+		}
+	} else {
+		for _, f := range sm.si.orderedFields {
+			if f.name != sm.key && !sm.componentsMap[f.name] {
+				values = append(values, f)
+			}
+		}
+	}
+	var validator TypeDesc = BytesType
+	for i, f := range values {
+		if i == 0 {
+			validator = f.cassandraType
+		} else if validator != f.cassandraType {
+			validator = BytesType
+		}
+	}
+	d.DefaultValidationClass = className(validator)
+
+	namedColumns := !compact && len(sm.components) == 0
+	for _, f := range sm.si.orderedFields {
+		if f.indexed && (!namedColumns || f.name == sm.key || sm.componentsMap[f.name]) {
+			return nil, errors.New(fmt.Sprint("Field ", f.name, " cannot be indexed, only value fields of sparse mappings without composites can"))
+		}
+	}
+	// counter column families cannot have other validators
+	if namedColumns && validator != CounterColumnType {
+		for _, f := range values {
+			c := &ColumnDefinition{Name: []byte(f.cassandraName), ValidationClass: className(f.cassandraType)}
+			if f.indexed {
+				c.IndexType = KeysIndex
+			}
+			d.Columns = append(d.Columns, c)
+		}
+	}
+
+	return d, nil
+}
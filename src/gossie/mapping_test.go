@@ -1,6 +1,7 @@
 package gossie
 
 import (
+	"io/ioutil"
 	"reflect"
 	"testing"
 )
@@ -225,3 +226,246 @@ func TestMap(t *testing.T) {
 		shell.checkFullMap(t)
 	}
 }
+
+type badKeyAndValues struct {
+	Key          int64  `cf:"AllTypes" key:"Key"`
+	ColAsciiType int64  `name:"colAsciiType"`
+	ColLongType  string `name:"colLongType"`
+	Other        int64
+}
+
+type badComponents struct {
+	Username string `cf:"ReasonableTwo" key:"Username" cols:"TweetID,Version"`
+	TweetID  string
+	Version  int64
+	Body     string
+}
+
+type sparseOverCompact struct {
+	Username string `cf:"CompositeFull" key:"Username" cols:"Bytes"`
+	Bytes    []byte
+	Body     string
+}
+
+type compactOverSparse struct {
+	Username string `cf:"ReasonableOne" key:"Username" cols:"TweetID" value:"Body" mapping:"compact"`
+	TweetID  int64
+	Body     string
+}
+
+func TestValidateMapping(t *testing.T) {
+	script, err := ioutil.ReadFile("../../schema-test.txt")
+	if err != nil {
+		t.Fatal("Error reading the test schema: ", err)
+	}
+	schema, err := ParseCliScript(string(script))
+	if err != nil {
+		t.Fatal("Error parsing the test schema: ", err)
+	}
+
+	for _, good := range []interface{}{&ReasonableZero{}, &ReasonableOne{}, &ReasonableTwo{}, &Timeseries{}, &CompositeFull{}, &BatchCounters{}} {
+		m, err := NewMapping(good)
+		if err != nil {
+			t.Fatal("Error building mapping: ", err)
+		}
+		if err = validateMapping(schema, m); err != nil {
+			t.Error("Unexpected mapping error for ", reflect.TypeOf(good), ": ", err)
+		}
+	}
+
+	checks := []struct {
+		source     interface{}
+		mismatches int
+	}{
+		{&tagsA{}, 1},
+		{&badKeyAndValues{}, 3},
+		{&badComponents{}, 1},
+		{&sparseOverCompact{}, 1},
+		{&compactOverSparse{}, 1},
+	}
+	for _, check := range checks {
+		m, err := NewMapping(check.source)
+		if err != nil {
+			t.Fatal("Error building mapping: ", err)
+		}
+		err = validateMapping(schema, m)
+		me, ok := err.(*MappingError)
+		if !ok {
+			t.Error("Expected a *MappingError for ", reflect.TypeOf(check.source), " but got ", err)
+			continue
+		}
+		if me.Cf != m.Cf() || len(me.Mismatches) != check.mismatches {
+			t.Error("Unexpected mismatches for ", reflect.TypeOf(check.source), ": ", me)
+		}
+	}
+}
+
+func TestStrictMapping(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	good, _ := NewMapping(&ReasonableZero{})
+	bad, _ := NewMapping(&badComponents{})
+
+	if err = cp.ValidateMapping(good); err != nil {
+		t.Error("Unexpected mapping error: ", err)
+	}
+	if _, ok := cp.ValidateMapping(bad).(*MappingError); !ok {
+		t.Error("Expected a *MappingError")
+	}
+
+	if _, err = cp.Query(bad).Strict(true).Get("user"); err == nil {
+		t.Error("Expected an error in a strict query with a bad mapping")
+	}
+	if _, err = cp.Query(bad).Get("user"); err != nil {
+		t.Error("Unexpected error in a non strict query: ", err)
+	}
+	if _, err = cp.Query(good).Strict(true).Get("user"); err != nil {
+		t.Error("Unexpected error in a strict query: ", err)
+	}
+
+	row := &badComponents{Username: "strict", TweetID: "1", Version: 1}
+	if err = cp.Batch().Strict(true).Insert(bad, row).Run(); err == nil {
+		t.Error("Expected an error in a strict batch with a bad mapping")
+	}
+	if err = cp.Batch().Strict(true).Insert(good, &ReasonableZero{Username: "strict"}).Run(); err != nil {
+		t.Error("Unexpected error in a strict batch: ", err)
+	}
+}
+
+type indexedUser struct {
+	Username string `cf:"IndexedUsers" key:"Username"`
+	Email    string `index:"true"`
+	Age      int    `name:"age" type:"Int32Type" index:"true"`
+	Bio      []byte
+}
+
+type mappedCounters struct {
+	Key    string `cf:"MappedCounters" key:"Key"`
+	Views  int64  `type:"CounterColumnType"`
+	Clicks int64  `type:"CounterColumnType"`
+}
+
+type badIndex struct {
+	Username string `cf:"BadIndex" key:"Username" cols:"TweetID"`
+	TweetID  int64
+	Body     string `index:"true"`
+}
+
+func TestDefinitionFromMapping(t *testing.T) {
+	checks := []struct {
+		source   interface{}
+		expected *ColumnFamilyDefinition
+	}{
+		{&ReasonableTwo{}, &ColumnFamilyDefinition{
+			Name:                   "ReasonableTwo",
+			Comparator:             "CompositeType(LongType,LongType,UTF8Type)",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "BytesType",
+			Columns:                []*ColumnDefinition{},
+		}},
+		{&Timeseries{}, &ColumnFamilyDefinition{
+			Name:                   "Timeseries",
+			Comparator:             "CompositeType(UUIDType,UTF8Type)",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "LongType",
+			Columns:                []*ColumnDefinition{},
+		}},
+		{&CompositeFull{}, &ColumnFamilyDefinition{
+			Name:                   "CompositeFull",
+			Comparator:             "CompositeType(BytesType,UTF8Type,UTF8Type,LongType,Int32Type,UUIDType,UUIDType,UUIDType,BooleanType,FloatType,DoubleType,DateType)",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "LongType",
+			Columns:                []*ColumnDefinition{},
+		}},
+		{&tagsC{}, &ColumnFamilyDefinition{
+			Name:                   "1",
+			Comparator:             "CompositeType(LongType,LongType)",
+			KeyValidationClass:     "LongType",
+			DefaultValidationClass: "LongType",
+			Columns:                []*ColumnDefinition{},
+		}},
+		{&indexedUser{}, &ColumnFamilyDefinition{
+			Name:                   "IndexedUsers",
+			Comparator:             "UTF8Type",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "BytesType",
+			Columns: []*ColumnDefinition{
+				&ColumnDefinition{Name: []byte("Email"), ValidationClass: "UTF8Type", IndexType: KeysIndex},
+				&ColumnDefinition{Name: []byte("age"), ValidationClass: "Int32Type", IndexType: KeysIndex},
+				&ColumnDefinition{Name: []byte("Bio"), ValidationClass: "BytesType"},
+			},
+		}},
+		{&mappedCounters{}, &ColumnFamilyDefinition{
+			Name:                   "MappedCounters",
+			Comparator:             "UTF8Type",
+			KeyValidationClass:     "UTF8Type",
+			DefaultValidationClass: "CounterColumnType",
+			Columns:                []*ColumnDefinition{},
+		}},
+	}
+
+	for _, check := range checks {
+		m, err := NewMapping(check.source)
+		if err != nil {
+			t.Fatal("Error building mapping: ", err)
+		}
+		d, err := DefinitionFromMapping(m)
+		if err != nil {
+			t.Error("Error generating definition for ", reflect.TypeOf(check.source), ": ", err)
+			continue
+		}
+		if !reflect.DeepEqual(d, check.expected) {
+			t.Error("Unexpected definition for ", reflect.TypeOf(check.source), ": ", NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{d}}).CliScript())
+		}
+		// the generated definition always matches the mapping
+		if err = validateMapping(NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{d}}), m); err != nil {
+			t.Error("Generated definition does not match the mapping of ", reflect.TypeOf(check.source), ": ", err)
+		}
+	}
+
+	m, _ := NewMapping(&badIndex{})
+	if _, err := DefinitionFromMapping(m); err == nil {
+		t.Error("Expected an error indexing a field of a composite mapping")
+	}
+}
+
+func TestEnsureColumnFamily(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: 10000})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	a := cp.Admin()
+
+	// leftovers from a failed run
+	a.DropColumnFamily("IndexedUsers")
+	defer a.DropColumnFamily("IndexedUsers")
+
+	m, _ := NewMapping(&indexedUser{})
+	if err = cp.EnsureColumnFamily(m); err != nil {
+		t.Fatal("Error creating the column family: ", err)
+	}
+	if cf := cp.Schema().ColumnFamilies["IndexedUsers"]; cf == nil || !cf.Indexed("Email") || !cf.Indexed("age") {
+		t.Fatal("Unexpected column family created: ", cf)
+	}
+	if err = cp.EnsureColumnFamily(m); err != nil {
+		t.Error("Error ensuring an existing column family: ", err)
+	}
+
+	if err = cp.Batch().Strict(true).Insert(m, &indexedUser{"user", "user@example.com", 30, nil}).Run(); err != nil {
+		t.Fatal("Error writing to the created column family: ", err)
+	}
+	rows, err := cp.Reader().Cf("IndexedUsers").Where([]byte("Email"), EQ, []byte("user@example.com")).IndexedGet(&IndexedRange{Count: 10})
+	if err != nil || len(rows) != 1 {
+		t.Error("Unexpected indexed read: ", rows, err)
+	}
+
+	bad, _ := NewMapping(&badComponents{})
+	if _, ok := cp.EnsureColumnFamily(bad).(*MappingError); !ok {
+		t.Error("Expected a *MappingError ensuring an existing column family with a bad mapping")
+	}
+}
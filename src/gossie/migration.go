@@ -0,0 +1,305 @@
+package gossie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// MIGRATIONS_CF is the column family where Migrate records the applied migration steps. It is
+	// created in the keyspace of the pool the first time a migration is applied.
+	MIGRATIONS_CF = "GossieMigrations"
+
+	// max number of recorded steps read back for a single migration
+	maxMigrationSteps = 100000
+)
+
+// MigrationStepKind is the type of schema change done by a MigrationStep
+type MigrationStepKind int
+
+const (
+	AddColumnFamilyStep MigrationStepKind = iota
+	AddColumnStep
+	AddIndexStep
+	ChangeValidatorStep
+)
+
+func (k MigrationStepKind) String() string {
+	switch k {
+	case AddColumnFamilyStep:
+		return "add column family"
+	case AddColumnStep:
+		return "add column"
+	case AddIndexStep:
+		return "add index"
+	case ChangeValidatorStep:
+		return "change validator"
+	}
+	return "unknown"
+}
+
+// MigrationStep is a single schema change of a migration plan. Definition is the whole column family
+// definition sent to Cassandra for the step, that is the live definition with this step and the
+// previous steps over the same column family applied. Column is the name of the affected column,
+// or nil for column family wide changes like adding the column family or changing its default or
+// key validators.
+type MigrationStep struct {
+	Kind       MigrationStepKind
+	Cf         string
+	Column     []byte
+	Validator  string // new validation class, for ChangeValidatorStep
+	Definition *ColumnFamilyDefinition
+}
+
+func (s *MigrationStep) String() string {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "%s %s", s.Kind, s.Cf)
+	if s.Column != nil {
+		fmt.Fprintf(b, "[%s]", s.Column)
+	}
+	if s.Kind == ChangeValidatorStep {
+		fmt.Fprintf(b, " to %s", s.Validator)
+	}
+	return b.String()
+}
+
+// PlanMigration compares a declarative description of a keyspace with its live schema and returns
+// the ordered steps that bring the schema up to the description: first the missing column
+// families are added, then the missing column metadata, then the missing indexes and finally the
+// changed validators. Migrations are additive, column families, columns and indexes not present in
+// the description are left untouched, and empty validation classes in the description mean any
+// validator is fine. The keyspace attributes are not migrated. Changes Cassandra cannot apply, like
+// a new comparator, return an error.
+func PlanMigration(desired *KeyspaceDefinition, current *Schema) ([]*MigrationStep, error) {
+	steps := make([]*MigrationStep, 0)
+	working := make(map[string]*ColumnFamilyDefinition)
+	existing := make([]*ColumnFamilyDefinition, 0)
+
+	for _, d := range desired.ColumnFamilies {
+		cf, found := current.ColumnFamilies[d.Name]
+		if !found {
+			steps = append(steps, &MigrationStep{Kind: AddColumnFamilyStep, Cf: d.Name, Definition: d.clone()})
+			continue
+		}
+		if cf.Definition == nil {
+			return nil, errors.New(fmt.Sprint("No definition for column family ", d.Name, " in the schema"))
+		}
+		if err := checkMigration(d, cf.Definition); err != nil {
+			return nil, err
+		}
+		working[d.Name] = cf.Definition.clone()
+		existing = append(existing, d)
+	}
+
+	// every step works over the definition left by the previous steps over the same column family
+	step := func(kind MigrationStepKind, d *ColumnFamilyDefinition, column []byte, validator string) {
+		steps = append(steps, &MigrationStep{
+			Kind:       kind,
+			Cf:         d.Name,
+			Column:     column,
+			Validator:  validator,
+			Definition: d.clone(),
+		})
+	}
+
+	for _, d := range existing {
+		w := working[d.Name]
+		for _, c := range d.Columns {
+			if w.column(c.Name) == nil {
+				w.Columns = append(w.Columns, &ColumnDefinition{Name: c.Name, ValidationClass: c.ValidationClass})
+				step(AddColumnStep, w, c.Name, "")
+			}
+		}
+	}
+
+	for _, d := range existing {
+		w := working[d.Name]
+		for _, c := range d.Columns {
+			wc := w.column(c.Name)
+			if c.IndexType != NoIndex && c.IndexType != wc.IndexType {
+				wc.IndexType = c.IndexType
+				wc.IndexName = c.IndexName
+				wc.IndexOptions = c.IndexOptions
+				step(AddIndexStep, w, c.Name, "")
+			}
+		}
+	}
+
+	for _, d := range existing {
+		w := working[d.Name]
+		if !sameClass(d.KeyValidationClass, w.KeyValidationClass) {
+			w.KeyValidationClass = d.KeyValidationClass
+			step(ChangeValidatorStep, w, nil, d.KeyValidationClass)
+		}
+		if !sameClass(d.DefaultValidationClass, w.DefaultValidationClass) {
+			w.DefaultValidationClass = d.DefaultValidationClass
+			step(ChangeValidatorStep, w, nil, d.DefaultValidationClass)
+		}
+		for _, c := range d.Columns {
+			wc := w.column(c.Name)
+			if !sameClass(c.ValidationClass, wc.ValidationClass) {
+				wc.ValidationClass = c.ValidationClass
+				step(ChangeValidatorStep, w, c.Name, c.ValidationClass)
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// checkMigration returns an error for the changes that cannot be done over an existing column family
+func checkMigration(desired, current *ColumnFamilyDefinition) error {
+	if desired.Super != current.Super {
+		return errors.New(fmt.Sprint("Cannot change the type of column family ", desired.Name))
+	}
+	if !sameClass(desired.Comparator, current.Comparator) {
+		return errors.New(fmt.Sprint("Cannot change the comparator of column family ", desired.Name))
+	}
+	if desired.Super && !sameClass(desired.SubComparator, current.SubComparator) {
+		return errors.New(fmt.Sprint("Cannot change the subcomparator of column family ", desired.Name))
+	}
+	return nil
+}
+
+// sameClass compares the desired and the current type classes, an empty desired class matches any
+// class. The classes are compared parsed, so the package, spacing and the two ways of writing
+// reversed types do not matter. Classes with types unknown to gossie are compared as text.
+func sameClass(desired, current string) bool {
+	if desired == "" {
+		return true
+	}
+	if knownClass(desired) && knownClass(current) {
+		return reflect.DeepEqual(parseTypeClass(desired), parseTypeClass(current))
+	}
+	return shortClass(desired) == shortClass(current)
+}
+
+// knownClass returns true if every type in the class is parsed by parseTypeClass
+func knownClass(class string) bool {
+	name, params := splitTypeParameters(class)
+	switch name {
+	case "ReversedType", "CompositeType":
+		for _, param := range params {
+			if !knownClass(param) {
+				return false
+			}
+		}
+		return len(params) > 0
+	case "BytesType":
+		return true
+	}
+	// parseTypeClass reads unknown types as BytesType
+	return typeDescFromName(name) != BytesType
+}
+
+func shortClass(class string) string {
+	return strings.Replace(strings.Replace(class, " ", "", -1), marshalPackage, "", -1)
+}
+
+func (d *ColumnFamilyDefinition) column(name []byte) *ColumnDefinition {
+	for _, c := range d.Columns {
+		if bytes.Equal(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// clone copies the definition and its column definitions, so they can be changed independently
+func (d *ColumnFamilyDefinition) clone() *ColumnFamilyDefinition {
+	c := *d
+	c.Columns = make([]*ColumnDefinition, 0, len(d.Columns))
+	for _, column := range d.Columns {
+		cc := *column
+		c.Columns = append(c.Columns, &cc)
+	}
+	return &c
+}
+
+func (a *admin) PlanMigration(desired *KeyspaceDefinition) ([]*MigrationStep, error) {
+	if desired.Name != "" && desired.Name != a.pool.keyspace {
+		return nil, errors.New(fmt.Sprint("The migration is for keyspace ", desired.Name, " but the pool is connected to ", a.pool.keyspace))
+	}
+	// plan against the live schema, not the last one seen by the pool
+	if _, err := a.pool.RefreshSchema(); err != nil {
+		return nil, err
+	}
+	return PlanMigration(desired, a.pool.Schema())
+}
+
+func (a *admin) Migrate(name string, desired *KeyspaceDefinition) ([]*MigrationStep, error) {
+	steps, err := a.PlanMigration(desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return steps, nil
+	}
+
+	if err = a.ensureMigrationsCf(); err != nil {
+		return nil, err
+	}
+	applied, err := a.MigrationLog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range steps {
+		if s.Kind == AddColumnFamilyStep {
+			err = a.AddColumnFamily(s.Definition)
+		} else {
+			err = a.UpdateColumnFamily(s.Definition)
+		}
+		if err != nil {
+			return steps[:i], errors.New(fmt.Sprint("Error applying migration step ", s, ": ", err))
+		}
+		row := &Row{Key: []byte(name), Columns: []*Column{&Column{
+			Name:  packMigrationStep(len(applied) + i),
+			Value: []byte(s.String()),
+		}}}
+		if err = a.pool.Writer().Insert(MIGRATIONS_CF, row).Run(); err != nil {
+			return steps[:i+1], errors.New(fmt.Sprint("Error recording migration step ", s, ": ", err))
+		}
+	}
+
+	return steps, nil
+}
+
+func (a *admin) MigrationLog(name string) ([]string, error) {
+	log := make([]string, 0)
+	if _, found := a.pool.Schema().ColumnFamilies[MIGRATIONS_CF]; !found {
+		return log, nil
+	}
+	row, err := a.pool.Reader().Cf(MIGRATIONS_CF).Slice(&Slice{Count: maxMigrationSteps}).Get([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return log, nil
+	}
+	for _, c := range row.Columns {
+		log = append(log, string(c.Value))
+	}
+	return log, nil
+}
+
+func (a *admin) ensureMigrationsCf() error {
+	if _, found := a.pool.Schema().ColumnFamilies[MIGRATIONS_CF]; found {
+		return nil
+	}
+	return a.AddColumnFamily(&ColumnFamilyDefinition{
+		Name:                   MIGRATIONS_CF,
+		Comparator:             "LongType",
+		KeyValidationClass:     "UTF8Type",
+		DefaultValidationClass: "UTF8Type",
+		Comment:                "Schema migrations applied by gossie",
+	})
+}
+
+func packMigrationStep(i int) []byte {
+	b, _ := Marshal(int64(i), LongType)
+	return b
+}
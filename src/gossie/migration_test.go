@@ -0,0 +1,168 @@
+package gossie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanMigration(t *testing.T) {
+	current := &Schema{ColumnFamilies: make(map[string]*ColumnFamily)}
+	current.ColumnFamilies["Users"] = &ColumnFamily{Definition: &ColumnFamilyDefinition{
+		Name:                   "Users",
+		Id:                     1000,
+		Comparator:             "org.apache.cassandra.db.marshal.UTF8Type",
+		KeyValidationClass:     "org.apache.cassandra.db.marshal.BytesType",
+		DefaultValidationClass: "org.apache.cassandra.db.marshal.BytesType",
+		Columns: []*ColumnDefinition{
+			&ColumnDefinition{Name: []byte("email"), ValidationClass: "org.apache.cassandra.db.marshal.BytesType"},
+			&ColumnDefinition{Name: []byte("name"), ValidationClass: "org.apache.cassandra.db.marshal.UTF8Type"},
+		},
+	}}
+	current.ColumnFamilies["Untouched"] = &ColumnFamily{Definition: &ColumnFamilyDefinition{Name: "Untouched"}}
+
+	desired := &KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{
+			Name:               "Users",
+			Comparator:         "UTF8Type",
+			KeyValidationClass: "UTF8Type",
+			Columns: []*ColumnDefinition{
+				&ColumnDefinition{Name: []byte("email"), ValidationClass: "AsciiType", IndexType: KeysIndex},
+				&ColumnDefinition{Name: []byte("name"), ValidationClass: "UTF8Type"},
+				&ColumnDefinition{Name: []byte("age"), ValidationClass: "LongType", IndexType: KeysIndex},
+			},
+		},
+		&ColumnFamilyDefinition{Name: "Events", Comparator: "TimeUUIDType"},
+	}}
+
+	steps, err := PlanMigration(desired, current)
+	if err != nil {
+		t.Fatal("Error planning migration: ", err)
+	}
+	descriptions := make([]string, 0)
+	for _, s := range steps {
+		descriptions = append(descriptions, s.String())
+	}
+	expected := []string{
+		"add column family Events",
+		"add column Users[age]",
+		"add index Users[email]",
+		"add index Users[age]",
+		"change validator Users to UTF8Type",
+		"change validator Users[email] to AsciiType",
+	}
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Fatal("Unexpected migration plan: ", descriptions)
+	}
+
+	// every step accumulates the previous ones over the same column family
+	age := steps[1].Definition.column([]byte("age"))
+	if age == nil || age.IndexType != NoIndex || steps[1].Definition.Id != 1000 {
+		t.Error("Unexpected definition for the add column step: ", steps[1].Definition)
+	}
+	last := steps[len(steps)-1].Definition
+	if last.column([]byte("age")).IndexType == NoIndex || last.column([]byte("email")).ValidationClass != "AsciiType" ||
+		last.KeyValidationClass != "UTF8Type" || len(last.Columns) != 3 {
+		t.Error("Unexpected definition for the last step: ", last)
+	}
+	if current.ColumnFamilies["Users"].Definition.column([]byte("email")).IndexType != NoIndex {
+		t.Error("The plan changed the current schema")
+	}
+
+	// applying the plan leaves nothing to do
+	applied := &Schema{ColumnFamilies: make(map[string]*ColumnFamily)}
+	applied.ColumnFamilies["Users"] = &ColumnFamily{Definition: last}
+	applied.ColumnFamilies["Events"] = &ColumnFamily{Definition: steps[0].Definition}
+	if steps, err = PlanMigration(desired, applied); err != nil || len(steps) != 0 {
+		t.Error("Expected an empty plan but got ", steps, err)
+	}
+
+	desired.ColumnFamilies[0].Comparator = "LongType"
+	if _, err = PlanMigration(desired, current); err == nil {
+		t.Error("Expected an error changing a comparator")
+	}
+
+	// the same types written in other forms are not changes
+	current.ColumnFamilies["Events"] = &ColumnFamily{Definition: &ColumnFamilyDefinition{
+		Name:                   "Events",
+		Comparator:             "org.apache.cassandra.db.marshal.CompositeType(org.apache.cassandra.db.marshal.UTF8Type,org.apache.cassandra.db.marshal.ReversedType(org.apache.cassandra.db.marshal.TimeUUIDType))",
+		KeyValidationClass:     "org.apache.cassandra.db.marshal.ReversedType(org.apache.cassandra.db.marshal.LongType)",
+		DefaultValidationClass: "com.example.CustomType",
+	}}
+	desired = &KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{
+			Name:                   "Events",
+			Comparator:             "CompositeType(UTF8Type, TimeUUIDType(reversed=true))",
+			KeyValidationClass:     "LongType(reversed=true)",
+			DefaultValidationClass: "com.example.CustomType",
+		},
+	}}
+	if steps, err = PlanMigration(desired, current); err != nil || len(steps) != 0 {
+		t.Error("Expected an empty plan for equal types but got ", steps, err)
+	}
+	desired.ColumnFamilies[0].Comparator = "CompositeType(UTF8Type, TimeUUIDType)"
+	if _, err = PlanMigration(desired, current); err == nil {
+		t.Error("Expected an error changing the order of a comparator component")
+	}
+	desired.ColumnFamilies[0].Comparator = "CompositeType(UTF8Type, TimeUUIDType(reversed=true))"
+	desired.ColumnFamilies[0].DefaultValidationClass = "com.example.OtherType"
+	if steps, err = PlanMigration(desired, current); err != nil || len(steps) != 1 {
+		t.Error("Expected a validator change between unknown types but got ", steps, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: 10000})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	a := cp.Admin()
+
+	// leftovers from a failed run
+	a.DropColumnFamily("Migrated")
+	a.DropColumnFamily(MIGRATIONS_CF)
+	defer a.DropColumnFamily(MIGRATIONS_CF)
+	defer a.DropColumnFamily("Migrated")
+
+	desired := &KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{
+			Name:               "Migrated",
+			Comparator:         "UTF8Type",
+			KeyValidationClass: "UTF8Type",
+			Columns: []*ColumnDefinition{
+				&ColumnDefinition{Name: []byte("a"), ValidationClass: "UTF8Type"},
+			},
+		},
+	}}
+
+	steps, err := a.Migrate("v1", desired)
+	if err != nil || len(steps) != 1 {
+		t.Fatal("Error applying the first migration: ", steps, err)
+	}
+
+	desired.ColumnFamilies[0].Columns = append(desired.ColumnFamilies[0].Columns,
+		&ColumnDefinition{Name: []byte("b"), ValidationClass: "LongType", IndexType: KeysIndex})
+	desired.ColumnFamilies[0].Columns[0].ValidationClass = "AsciiType"
+	if steps, err = a.Migrate("v2", desired); err != nil || len(steps) != 3 {
+		t.Fatal("Error applying the second migration: ", steps, err)
+	}
+	if steps, err = a.Migrate("v2", desired); err != nil || len(steps) != 0 {
+		t.Error("Expected nothing to do applying the same migration again: ", steps, err)
+	}
+
+	cf := cp.Schema().ColumnFamilies["Migrated"]
+	if cf == nil || !cf.Indexed("b") || cf.NamedColumns["a"].Desc != AsciiType || cf.NamedColumns["b"].Desc != LongType {
+		t.Error("Unexpected schema after the migrations: ", cf)
+	}
+
+	log, err := a.MigrationLog("v2")
+	if err != nil {
+		t.Fatal("Error reading the migration log: ", err)
+	}
+	if !reflect.DeepEqual(log, []string{"add column Migrated[b]", "add index Migrated[b]", "change validator Migrated[a] to AsciiType"}) {
+		t.Error("Unexpected migration log: ", log)
+	}
+	if log, err = a.MigrationLog("v3"); err != nil || len(log) != 0 {
+		t.Error("Unexpected log for an unapplied migration: ", log, err)
+	}
+}
@@ -0,0 +1,346 @@
+package gossie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/carloscm/gossie/src/cassandra"
+	"unicode/utf8"
+)
+
+// MutationKind is the type of operation described by a Mutation
+type MutationKind int
+
+const (
+	InsertMutation MutationKind = iota
+	SuperInsertMutation
+	CounterMutation
+	DeleteMutation
+	CounterRemoveMutation
+)
+
+func (k MutationKind) String() string {
+	switch k {
+	case InsertMutation:
+		return "insert"
+	case SuperInsertMutation:
+		return "super insert"
+	case CounterMutation:
+		return "counter delta"
+	case DeleteMutation:
+		return "delete"
+	case CounterRemoveMutation:
+		return "counter remove"
+	}
+	return "unknown"
+}
+
+// Mutation is a readable description of an operation added to a Writer. Row always contains the
+// key. For insertions it also contains the written columns, or the written super columns for super
+// insertions. For counter deltas the column values are the marshaled LongType deltas. For
+// deletions of columns by name and counter removals it contains the names of the deleted columns.
+type Mutation struct {
+	Kind        MutationKind
+	Cf          string
+	Row         *Row
+	SuperColumn []byte // deleted super column, if any
+	Slice       *Slice // deleted slice, for slice deletions
+	Timestamp   int64  // timestamp of the deletion
+}
+
+// Size returns an estimation of the size in bytes of the mutation when sent to Cassandra
+func (m *Mutation) Size() int {
+	size := len(m.Cf) + rowSize(m.Row) + len(m.SuperColumn)
+	for _, sc := range m.Row.SuperColumns {
+		size += len(sc.Name)
+		for _, c := range sc.Columns {
+			size += len(c.Name) + len(c.Value) + bulkColumnOverhead
+		}
+	}
+	if m.Slice != nil {
+		size += len(m.Slice.Start) + len(m.Slice.End)
+	}
+	return size
+}
+
+func (m *Mutation) String() string {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "%s %s[%x]", m.Kind, m.Cf, m.Row.Key)
+	if m.SuperColumn != nil {
+		fmt.Fprintf(b, "[%x]", m.SuperColumn)
+	}
+	for _, c := range m.Row.Columns {
+		if m.Kind == DeleteMutation || m.Kind == CounterRemoveMutation {
+			fmt.Fprintf(b, " %x", c.Name)
+		} else {
+			fmt.Fprintf(b, " %x=%x", c.Name, c.Value)
+		}
+	}
+	for _, sc := range m.Row.SuperColumns {
+		fmt.Fprintf(b, " %x:{", sc.Name)
+		for i, c := range sc.Columns {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "%x=%x", c.Name, c.Value)
+		}
+		b.WriteString("}")
+	}
+	if m.Slice != nil {
+		fmt.Fprintf(b, " %x..%x count %d", m.Slice.Start, m.Slice.End, m.Slice.Count)
+		if m.Slice.Reversed {
+			b.WriteString(" reversed")
+		}
+	}
+	if m.Kind == DeleteMutation {
+		fmt.Fprintf(b, " at %d", m.Timestamp)
+	}
+	return b.String()
+}
+
+// writerMutation keeps the column family and key of a low level mutation, in the order it was
+// added. Counter removals have no low level mutation since they are sent with remove_counter.
+type writerMutation struct {
+	cf             string
+	key            []byte
+	mutation       *cassandra.Mutation
+	counterRemoval bool
+	column         []byte
+}
+
+// writerColumn identifies a single column written by a writer, for merging duplicated writes
+type writerColumn struct {
+	cf   string
+	key  string
+	name string
+}
+
+func describeMutation(wm *writerMutation) *Mutation {
+	m := &Mutation{Cf: wm.cf, Row: &Row{Key: wm.key}}
+	if wm.counterRemoval {
+		m.Kind = CounterRemoveMutation
+		if wm.column != nil {
+			m.Row.Columns = []*Column{&Column{Name: wm.column}}
+		}
+		return m
+	}
+	if cs := wm.mutation.ColumnOrSupercolumn; cs != nil {
+		switch {
+		case cs.Column != nil:
+			m.Kind = InsertMutation
+			m.Row.Columns = []*Column{columnFromTColumnOrSuperColumn(cs)}
+		case cs.CounterColumn != nil:
+			m.Kind = CounterMutation
+			m.Row.Columns = []*Column{columnFromTColumnOrSuperColumn(cs)}
+		default:
+			m.Kind = SuperInsertMutation
+			m.Row.SuperColumns = []*SuperColumn{superColumnFromTColumnOrSuperColumn(cs)}
+		}
+		return m
+	}
+
+	d := wm.mutation.Deletion
+	m.Kind = DeleteMutation
+	m.Timestamp = d.Timestamp
+	m.SuperColumn = d.SuperColumn
+	if d.Predicate != nil {
+		if sr := d.Predicate.SliceRange; sr != nil {
+			m.Slice = &Slice{
+				Start:    sr.Start,
+				End:      sr.Finish,
+				Count:    int(sr.Count),
+				Reversed: sr.Reversed,
+			}
+		} else if d.Predicate.ColumnNames != nil {
+			for nameI := range d.Predicate.ColumnNames.Iter() {
+				m.Row.Columns = append(m.Row.Columns, &Column{Name: nameI.([]byte)})
+			}
+		}
+	}
+	return m
+}
+
+func (w *writer) Mutations() []*Mutation {
+	mutations := make([]*Mutation, 0)
+	var last *Mutation
+	for _, wm := range w.order {
+		m := describeMutation(wm)
+		// columns added by the same insertion are grouped back in a single row
+		if last != nil && last.Kind == m.Kind && last.Cf == m.Cf && bytes.Equal(last.Row.Key, m.Row.Key) &&
+			(m.Kind == InsertMutation || m.Kind == CounterMutation) {
+			last.Row.Columns = append(last.Row.Columns, m.Row.Columns...)
+			continue
+		}
+		mutations = append(mutations, m)
+		last = m
+	}
+	return mutations
+}
+
+func (w *writer) DryRun() error {
+	schema := w.pool.Schema()
+	for _, m := range w.Mutations() {
+		if err := validateMutation(schema, m); err != nil {
+			return errors.New(fmt.Sprint("Invalid mutation ", m, ": ", err))
+		}
+	}
+	return nil
+}
+
+func validateMutation(schema *Schema, m *Mutation) error {
+	cf, found := schema.ColumnFamilies[m.Cf]
+	if !found {
+		return errors.New(fmt.Sprint("Column family ", m.Cf, " not found in the keyspace schema"))
+	}
+	if len(m.Row.Key) <= 0 {
+		return errors.New("Empty row key")
+	}
+	if err := validateValue(m.Row.Key, cf.KeyValidator); err != nil {
+		return errors.New(fmt.Sprint("Invalid row key: ", err))
+	}
+	counters := cf.DefaultValidator.Desc == CounterColumnType
+
+	switch m.Kind {
+	case InsertMutation:
+		if cf.Super {
+			return errors.New("Cannot insert columns without a super column in a super column family")
+		}
+		if counters {
+			return errors.New("Cannot insert columns in a counter column family")
+		}
+		for _, c := range m.Row.Columns {
+			if err := validateColumn(c, cf.DefaultComparator, cf); err != nil {
+				return err
+			}
+		}
+
+	case SuperInsertMutation:
+		if !cf.Super {
+			return errors.New("Cannot insert super columns in a standard column family")
+		}
+		for _, sc := range m.Row.SuperColumns {
+			if err := validateName(sc.Name, cf.DefaultComparator); err != nil {
+				return err
+			}
+			for _, c := range sc.Columns {
+				if err := validateColumn(c, cf.SubComparator, cf); err != nil {
+					return err
+				}
+			}
+		}
+
+	case CounterMutation, CounterRemoveMutation:
+		if !counters {
+			return errors.New("Cannot apply counter operations in a column family without counter columns")
+		}
+		if m.Kind == CounterRemoveMutation && cf.Super {
+			return errors.New("Cannot remove counters in a super column family")
+		}
+		for _, c := range m.Row.Columns {
+			if err := validateName(c.Name, cf.DefaultComparator); err != nil {
+				return err
+			}
+		}
+
+	case DeleteMutation:
+		if counters {
+			return errors.New("Cannot delete counter columns, use RemoveCounter")
+		}
+		if m.SuperColumn != nil && !cf.Super {
+			return errors.New("Cannot delete a super column in a standard column family")
+		}
+		comparator := cf.DefaultComparator
+		if m.SuperColumn != nil {
+			comparator = cf.SubComparator
+		}
+		for _, c := range m.Row.Columns {
+			if err := validateName(c.Name, comparator); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateName(name []byte, comparator TypeClass) error {
+	if len(name) <= 0 {
+		return errors.New("Empty column name")
+	}
+	if err := validateValue(name, comparator); err != nil {
+		return errors.New(fmt.Sprint("Invalid column name ", name, ": ", err))
+	}
+	return nil
+}
+
+func validateColumn(c *Column, comparator TypeClass, cf *ColumnFamily) error {
+	if err := validateName(c.Name, comparator); err != nil {
+		return err
+	}
+	validator, found := cf.NamedColumns[string(c.Name)]
+	if !found {
+		validator = cf.DefaultValidator
+	}
+	if err := validateValue(c.Value, validator); err != nil {
+		return errors.New(fmt.Sprint("Invalid value for column ", c.Name, ": ", err))
+	}
+	if c.Ttl < 0 {
+		return errors.New(fmt.Sprint("Negative ttl for column ", c.Name))
+	}
+	return nil
+}
+
+// validateValue checks the serialization of b for the types with a fixed size or encoding. Empty
+// values are allowed for every type.
+func validateValue(b []byte, tc TypeClass) error {
+	if len(b) <= 0 {
+		return nil
+	}
+	size := 0
+	switch tc.Desc {
+	case LongType, DateType, DoubleType, CounterColumnType:
+		size = 8
+	case Int32Type, FloatType:
+		size = 4
+	case UUIDType, TimeUUIDType, LexicalUUIDType:
+		size = 16
+	case BooleanType:
+		size = 1
+	case AsciiType:
+		for _, c := range b {
+			if c >= 0x80 {
+				return errors.New("Not an ASCII string")
+			}
+		}
+	case UTF8Type:
+		if !utf8.Valid(b) {
+			return errors.New("Not an UTF-8 string")
+		}
+	case CompositeType:
+		return validateComposite(b, tc)
+	}
+	if size > 0 && len(b) != size {
+		return errors.New(fmt.Sprint("Expected ", size, " bytes but got ", len(b)))
+	}
+	return nil
+}
+
+func validateComposite(b []byte, tc TypeClass) error {
+	for i := 0; len(b) > 0; i++ {
+		if len(b) < 3 {
+			return errors.New("Truncated composite component")
+		}
+		l := int(b[0])<<8 | int(b[1])
+		if len(b) < 3+l {
+			return errors.New("Truncated composite component")
+		}
+		if i >= len(tc.Components) {
+			return errors.New(fmt.Sprint("Too many composite components, the comparator has ", len(tc.Components)))
+		}
+		if err := validateValue(b[2:2+l], tc.Components[i]); err != nil {
+			return errors.New(fmt.Sprint("Composite component ", i, ": ", err))
+		}
+		b = b[3+l:]
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package gossie
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"reflect"
+	"strings"
 )
 
 /*
@@ -11,12 +15,16 @@ todo:
 */
 
 var (
-	Done = errors.New("No more results found")
+	Done              = errors.New("No more results found")
+	ErrorCqlNoRows    = errors.New("The CQL query did not return rows")
+	ErrorCqlNotSelect = errors.New("Only CQL SELECT queries can be read into structs")
 )
 
 const (
 	DEFAULT_COLUMN_LIMIT = 10000
 	DEFAULT_ROW_LIMIT    = 500
+	DEFAULT_PAGE_COLUMNS = 1000
+	DEFAULT_PAGE_ROWS    = 20
 )
 
 // Query is a high level interface for Cassandra queries
@@ -30,9 +38,17 @@ type Query interface {
 	// Limit sets the column and rows to buffer at once.
 	Limit(columns, rows int) Query
 
+	// PageSize sets the columns and rows fetched per page by Each and Stream, independently of
+	// Limit. It defaults to DEFAULT_PAGE_COLUMNS columns and DEFAULT_PAGE_ROWS rows.
+	PageSize(columns, rows int) Query
+
 	// Reverse set to true will reverse the order of the columns in the result.
 	Reversed(bool) Query
 
+	// Strict set to true validates the mapping against the keyspace schema before reading, see
+	// ConnectionPool.ValidateMapping. Reads fail with the *MappingError if there are mismatches.
+	Strict(bool) Query
+
 	// Components buils a column slice for Get operations with fixed values
 	// for the passed components. It fills those components in the same order
 	// as in the method arguments.
@@ -41,15 +57,37 @@ type Query interface {
 	// Between allows to pass different values for the last components of the
 	// Start and End columns in the column slice for Get operations. Do not
 	// pass the last component to Component() when usign Between(). start is
-	// inclusive, end is exclusive.
+	// inclusive, end is exclusive. If the component is reversed in the
+	// comparator the slice bounds are swapped, so start is still the lower
+	// value.
 	Between(start, end interface{}) Query
 
 	// Get looks up a row with the given key. If the row uses a composite
 	// column names the Result will allow you to iterate over the entire row.
 	Get(key interface{}) (Result, error)
 
-	// MultiGet looks up multiple rows given the keys. 
+	// MultiGet looks up multiple rows given the keys.
 	MultiGet(keys []interface{}) (Result, error)
+
+	// Cql runs a CQL 2 SELECT query, see ConnectionPool.Cql, and returns a Result that reads
+	// the returned rows into structs the same way Get does. Other statements are rejected with
+	// ErrorCqlNotSelect before being sent to Cassandra. The row key column that CQL adds to
+	// the results is skipped. Only the selected columns are read, and rows with as many columns as
+	// the column Limit are taken as incomplete like in Get, so use the same value for the FIRST
+	// clause of the query.
+	Cql(query string, args ...interface{}) (Result, error)
+
+	// Each looks up the rows with the given keys and calls f every time a new object has been
+	// read into destination. Rows and columns are fetched lazily, in pages of the sizes set by
+	// PageSize, so only the current page is buffered. Iteration stops with the first error returned
+	// by f, which is returned by Each, or when ctx is done, returning ctx.Err().
+	Each(ctx context.Context, keys []interface{}, destination interface{}, f func() error) error
+
+	// Stream works like Each, but it sends a new object of the same type as prototype through the
+	// returned objects channel for every object read. The objects channel is unbuffered so the
+	// reads follow the pace of the receiver. Both channels are closed when there are no more
+	// objects, with at most one error sent over the error channel. Cancel ctx to stop reading.
+	Stream(ctx context.Context, keys []interface{}, prototype interface{}) (<-chan interface{}, <-chan error)
 }
 
 // Result reads Query results into Go objects, internally buffering them.
@@ -67,7 +105,10 @@ type query struct {
 	consistencyLevel int
 	columnLimit      int
 	rowLimit         int
+	pageColumns      int
+	pageRows         int
 	reversed         bool
+	strict           bool
 	components       []interface{}
 	betweenStart     interface{}
 	betweenEnd       interface{}
@@ -79,6 +120,8 @@ func newQuery(cp *connectionPool, m Mapping) *query {
 		mapping:     m,
 		columnLimit: DEFAULT_COLUMN_LIMIT,
 		rowLimit:    DEFAULT_ROW_LIMIT,
+		pageColumns: DEFAULT_PAGE_COLUMNS,
+		pageRows:    DEFAULT_PAGE_ROWS,
 		components:  make([]interface{}, 0),
 	}
 }
@@ -94,11 +137,22 @@ func (q *query) Limit(columns, rows int) Query {
 	return q
 }
 
+func (q *query) PageSize(columns, rows int) Query {
+	q.pageColumns = columns
+	q.pageRows = rows
+	return q
+}
+
 func (q *query) Reversed(r bool) Query {
 	q.reversed = r
 	return q
 }
 
+func (q *query) Strict(s bool) Query {
+	q.strict = s
+	return q
+}
+
 func (q *query) Components(components ...interface{}) Query {
 	q.components = components
 	return q
@@ -115,25 +169,21 @@ func (q *query) Get(key interface{}) (Result, error) {
 }
 
 func (q *query) MultiGet(keys []interface{}) (Result, error) {
-	var err error
-
-	keysB := make([][]byte, 0)
-
-	for _, key := range keys {
-		keyB, err := q.mapping.MarshalKey(key)
-		if err != nil {
-			return nil, err
-		}
-		keysB = append(keysB, keyB)
+	if err := q.validate(); err != nil {
+		return nil, err
 	}
 
-	reader := q.pool.Reader().Cf(q.mapping.Cf())
+	keysB, err := q.marshalKeys(keys)
+	if err != nil {
+		return nil, err
+	}
 
-	if q.consistencyLevel != 0 {
-		reader.ConsistencyLevel(q.consistencyLevel)
+	slice, err := q.buildSlice()
+	if err != nil {
+		return nil, err
 	}
 
-	q.buildSlice(reader)
+	reader := q.buildReader().Slice(slice)
 
 	rows := make([]*Row, 0)
 
@@ -155,7 +205,156 @@ func (q *query) MultiGet(keys []interface{}) (Result, error) {
 	return &result{query: *q, buffer: rows}, nil
 }
 
-func (q *query) buildSlice(reader Reader) error {
+func (q *query) Each(ctx context.Context, keys []interface{}, destination interface{}, f func() error) error {
+	res, err := q.paged(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for {
+		err := res.Next(destination)
+		if err == Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+}
+
+func (q *query) Stream(ctx context.Context, keys []interface{}, prototype interface{}) (<-chan interface{}, <-chan error) {
+	objects := make(chan interface{})
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(objects)
+		defer close(errs)
+
+		t := reflect.TypeOf(prototype)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+			errs <- errors.New("Passed prototype is not a pointer to a struct")
+			return
+		}
+
+		res, err := q.paged(ctx, keys)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		for {
+			destination := reflect.New(t.Elem()).Interface()
+			err := res.Next(destination)
+			if err == Done {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case objects <- destination:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return objects, errs
+}
+
+func (q *query) Cql(query string, args ...interface{}) (Result, error) {
+	if !strings.EqualFold(cqlKeyword(query), "SELECT") {
+		return nil, ErrorCqlNotSelect
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	res, err := q.pool.Cql(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return q.cqlResult(res)
+}
+
+// cqlResult builds a Result over the rows of a CQL result, without their key columns
+func (q *query) cqlResult(res *CqlResult) (Result, error) {
+	// SELECT always returns rows, this is only a safety check
+	if res.Type != CqlRows {
+		return nil, ErrorCqlNoRows
+	}
+
+	alias := []byte("KEY")
+	if schema := q.pool.Schema(); schema != nil {
+		if cf, found := schema.ColumnFamilies[q.mapping.Cf()]; found && cf.Definition != nil && len(cf.Definition.KeyAlias) > 0 {
+			alias = cf.Definition.KeyAlias
+		}
+	}
+
+	rows := make([]*Row, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		columns := make([]*Column, 0, len(row.Columns))
+		for _, c := range row.Columns {
+			if bytes.EqualFold(c.Name, alias) && bytes.Equal(c.Value, row.Key) {
+				continue
+			}
+			columns = append(columns, c)
+		}
+		// CQL returns deleted rows with only their key
+		if len(columns) > 0 {
+			rows = append(rows, &Row{Key: row.Key, Columns: columns})
+		}
+	}
+
+	return &result{query: *q, buffer: rows}, nil
+}
+
+// validate checks the mapping against the schema in strict mode
+func (q *query) validate() error {
+	if !q.strict {
+		return nil
+	}
+	return q.pool.ValidateMapping(q.mapping)
+}
+
+func (q *query) marshalKeys(keys []interface{}) ([][]byte, error) {
+	keysB := make([][]byte, 0)
+	for _, key := range keys {
+		keyB, err := q.mapping.MarshalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keysB = append(keysB, keyB)
+	}
+	return keysB, nil
+}
+
+func (q *query) buildReader() Reader {
+	reader := q.pool.Reader().Cf(q.mapping.Cf())
+	if q.consistencyLevel != 0 {
+		reader.ConsistencyLevel(q.consistencyLevel)
+	}
+	return reader
+}
+
+// reversedComponent tells if the component at position i of the comparator is declared as reversed
+// in the schema, in which case Cassandra sorts its values in descending order
+func (q *query) reversedComponent(i int) bool {
+	schema := q.pool.Schema()
+	if schema == nil {
+		return false
+	}
+	cf, found := schema.ColumnFamilies[q.mapping.Cf()]
+	if !found || i >= len(cf.DefaultComparator.Components) {
+		return false
+	}
+	return cf.DefaultComparator.Components[i].Reversed
+}
+
+func (q *query) buildSlice() (*Slice, error) {
 	start := make([]byte, 0)
 	end := make([]byte, 0)
 
@@ -169,27 +368,39 @@ func (q *query) buildSlice(reader Reader) error {
 		for i, c := range components {
 			b, err := q.mapping.MarshalComponent(c, i)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			start = append(start, packComposite(b, eocEquals)...)
-			if i == last {
-				if q.betweenEnd != nil {
-					b, err := q.mapping.MarshalComponent(q.betweenEnd, i)
-					if err != nil {
-						return err
-					}
-					end = append(end, packComposite(b, eocEquals)...)
-				} else {
+			if i < last {
+				start = append(start, packComposite(b, eocEquals)...)
+				end = append(end, packComposite(b, eocEquals)...)
+			} else if q.betweenEnd == nil {
+				start = append(start, packComposite(b, eocEquals)...)
+				end = append(end, packComposite(b, eocGreater)...)
+			} else {
+				e, err := q.mapping.MarshalComponent(q.betweenEnd, i)
+				if err != nil {
+					return nil, err
+				}
+				if q.reversedComponent(i) {
+					// values are stored in descending order, so the columns from betweenStart
+					// (inclusive) to betweenEnd (exclusive) go after all the columns for
+					// betweenEnd and up to the last column for betweenStart
+					start = append(start, packComposite(e, eocGreater)...)
 					end = append(end, packComposite(b, eocGreater)...)
+				} else {
+					start = append(start, packComposite(b, eocEquals)...)
+					end = append(end, packComposite(e, eocEquals)...)
 				}
-			} else {
-				end = append(end, packComposite(b, eocEquals)...)
 			}
 		}
 	}
 
-	reader.Slice(&Slice{Start: start, End: end, Count: q.columnLimit, Reversed: q.reversed})
-	return nil
+	// a reversed slice goes from the end of the range to its start
+	if q.reversed {
+		start, end = end, start
+	}
+
+	return &Slice{Start: start, End: end, Count: q.columnLimit, Reversed: q.reversed}, nil
 }
 
 type result struct {
@@ -250,3 +461,152 @@ func (r *result) Next(destination interface{}) error {
 	}
 	return err
 }
+
+// pagedResult is a Result that reads rows and columns lazily as Next is called. Rows are fetched
+// in batches of up to pageRows keys and wide rows are continued in pages of up to pageColumns
+// columns, so only the current pages are buffered at any time.
+type pagedResult struct {
+	query
+	ctx      context.Context
+	slice    *Slice
+	keys     [][]byte
+	buffer   []*Row
+	row      *Row
+	full     bool
+	position int
+}
+
+func (q *query) paged(ctx context.Context, keys []interface{}) (*pagedResult, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	keysB, err := q.marshalKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	slice, err := q.buildSlice()
+	if err != nil {
+		return nil, err
+	}
+	slice.Count = q.pageColumns
+	// paging needs at least the last column of the previous page plus a new one
+	if slice.Count < 2 {
+		slice.Count = 2
+	}
+	return &pagedResult{query: *q, ctx: ctx, slice: slice, keys: keysB}, nil
+}
+
+func (r *pagedResult) fetchRows() error {
+	n := r.pageRows
+	if n <= 0 || n > len(r.keys) {
+		n = len(r.keys)
+	}
+	keys := r.keys[:n]
+	r.keys = r.keys[n:]
+
+	rows, err := r.buildReader().Slice(r.slice).KeyOrder(true).MultiGet(keys)
+	if err != nil {
+		return err
+	}
+	r.buffer = rows
+	return nil
+}
+
+func (r *pagedResult) fetchColumns() error {
+	last := r.row.Columns[len(r.row.Columns)-1]
+	slice := *r.slice
+	slice.Start = last.Name
+
+	row, err := r.buildReader().Slice(&slice).Get(r.row.Key)
+	if err != nil {
+		return err
+	}
+
+	var columns []*Column
+	if row != nil {
+		columns = row.Columns
+		r.full = len(columns) >= slice.Count
+		// the slice start is inclusive so skip the already read column
+		if len(columns) > 0 && bytes.Equal(columns[0].Name, last.Name) {
+			columns = columns[1:]
+		}
+	} else {
+		r.full = false
+	}
+
+	// keep the last column of the previous page around so Rewind still works
+	r.row = &Row{Key: r.row.Key, Columns: append([]*Column{last}, columns...)}
+	r.position = 1
+	return nil
+}
+
+func (r *pagedResult) feedRow() error {
+	for r.row == nil {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		if len(r.buffer) <= 0 {
+			if len(r.keys) <= 0 {
+				return Done
+			}
+			if err := r.fetchRows(); err != nil {
+				return err
+			}
+			continue
+		}
+		row := r.buffer[0]
+		r.buffer = r.buffer[1:len(r.buffer)]
+		// rows are returned in key order, including the missing ones as empty rows
+		if len(row.Columns) <= 0 {
+			continue
+		}
+		r.row = row
+		r.full = len(row.Columns) >= r.slice.Count
+		r.position = 0
+	}
+	return nil
+}
+
+func (r *pagedResult) Key() ([]byte, error) {
+	if err := r.feedRow(); err != nil {
+		return nil, err
+	}
+	return r.row.Key, nil
+}
+
+func (r *pagedResult) NextColumn() (*Column, error) {
+	if err := r.feedRow(); err != nil {
+		return nil, err
+	}
+	if r.position >= len(r.row.Columns) && r.full {
+		if err := r.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := r.fetchColumns(); err != nil {
+			return nil, err
+		}
+	}
+	if r.position >= len(r.row.Columns) {
+		return nil, EndBeforeLimit
+	}
+	c := r.row.Columns[r.position]
+	r.position++
+	return c, nil
+}
+
+func (r *pagedResult) Rewind() {
+	r.position--
+	if r.position < 0 {
+		r.position = 0
+	}
+}
+
+func (r *pagedResult) Next(destination interface{}) error {
+	err := r.mapping.Unmap(destination, r)
+	if err == Done {
+		// force new row feed and try again, just once
+		r.row = nil
+		err = r.mapping.Unmap(destination, r)
+	}
+	return err
+}
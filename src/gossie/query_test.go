@@ -1,6 +1,8 @@
 package gossie
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -423,6 +425,55 @@ func TestQueryGet(t *testing.T) {
 		t.Fatal("Result Next is not Done:", err)
 	}
 
+	// the TimeUUID component is reversed in the comparator, so Between must swap the slice bounds
+	seqBase = createTimeseries(t, cp)
+	all := make([]*Timeseries, 0)
+	res, err = cp.Query(mT).Get("testuser")
+	if err != nil {
+		t.Fatal("Query get error:", err)
+	}
+	for {
+		r := &Timeseries{}
+		if err = res.Next(r); err != nil {
+			break
+		}
+		all = append(all, r)
+	}
+	if len(all) != 100 {
+		t.Fatal("Unexpected number of reads: ", len(all))
+	}
+	// all[89] is seqBase+10 and all[79] is seqBase+20
+	res, err = cp.Query(mT).Between(all[89].TimeUUID, all[79].TimeUUID).Get("testuser")
+	if err != nil {
+		t.Fatal("Query get error:", err)
+	}
+	for i := 19; i >= 10; i-- {
+		if err = res.Next(rT); err != nil {
+			t.Fatal("Result next error:", err)
+		}
+		if rT.Seq != seqBase+i {
+			t.Error("Read does not match Write")
+		}
+	}
+	if err = res.Next(rT); err != Done {
+		t.Fatal("Result Next is not Done:", err)
+	}
+	res, err = cp.Query(mT).Between(all[89].TimeUUID, all[79].TimeUUID).Reversed(true).Get("testuser")
+	if err != nil {
+		t.Fatal("Query get error:", err)
+	}
+	for i := 10; i < 20; i++ {
+		if err = res.Next(rT); err != nil {
+			t.Fatal("Result next error:", err)
+		}
+		if rT.Seq != seqBase+i {
+			t.Error("Read does not match Write")
+		}
+	}
+	if err = res.Next(rT); err != Done {
+		t.Fatal("Result Next is not Done:", err)
+	}
+
 	/////
 
 	r2 := &ReasonableTwo{}
@@ -607,3 +658,170 @@ func TestQueryGet(t *testing.T) {
 	}
 
 }
+
+func TestQueryBuildSlice(t *testing.T) {
+	d := &KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{Name: "ReasonableTwo", Comparator: "CompositeType(LongType,ReversedType(LongType),UTF8Type)"},
+	}}
+	cp := &connectionPool{schema: NewSchema(d)}
+	m, _ := NewMapping(&ReasonableTwo{})
+	component := func(v int64, eoc byte) []byte {
+		b, _ := Marshal(v, LongType)
+		return packComposite(b, eoc)
+	}
+	prefix := component(1, eocEquals)
+
+	checks := []struct {
+		q          Query
+		start, end []byte
+	}{
+		{newQuery(cp, m).Components(int64(1)), component(1, eocEquals), component(1, eocGreater)},
+		{newQuery(cp, m).Components(int64(1)).Reversed(true), component(1, eocGreater), component(1, eocEquals)},
+		{newQuery(cp, m).Between(int64(1), int64(5)), component(1, eocEquals), component(5, eocEquals)},
+		{newQuery(cp, m).Between(int64(1), int64(5)).Reversed(true), component(5, eocEquals), component(1, eocEquals)},
+		{newQuery(cp, m).Components(int64(1)).Between(int64(2), int64(5)),
+			append(append([]byte{}, prefix...), component(5, eocGreater)...),
+			append(append([]byte{}, prefix...), component(2, eocGreater)...)},
+		{newQuery(cp, m).Components(int64(1)).Between(int64(2), int64(5)).Reversed(true),
+			append(append([]byte{}, prefix...), component(2, eocGreater)...),
+			append(append([]byte{}, prefix...), component(5, eocGreater)...)},
+	}
+	for i, check := range checks {
+		slice, err := check.q.(*query).buildSlice()
+		if err != nil {
+			t.Fatal("Error building slice: ", err)
+		}
+		if !reflect.DeepEqual(slice.Start, check.start) || !reflect.DeepEqual(slice.End, check.end) {
+			t.Errorf("Unexpected slice for check %d: %x..%x", i, slice.Start, slice.End)
+		}
+	}
+}
+
+func TestQueryPageSize(t *testing.T) {
+	cp := &connectionPool{schema: NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{Name: "ReasonableOne", Comparator: "CompositeType(LongType,AsciiType)"},
+	}})}
+	m, _ := NewMapping(&ReasonableOne{})
+	keys := []interface{}{"a", "b"}
+
+	// the limits of Get and MultiGet do not change the pages
+	res, err := newQuery(cp, m).Limit(5, 5).(*query).paged(context.Background(), keys)
+	if err != nil {
+		t.Fatal("Error building paged result: ", err)
+	}
+	if res.slice.Count != DEFAULT_PAGE_COLUMNS || res.pageRows != DEFAULT_PAGE_ROWS {
+		t.Error("Unexpected default page size: ", res.slice.Count, " columns and ", res.pageRows, " rows")
+	}
+
+	res, err = newQuery(cp, m).PageSize(7, 1).(*query).paged(context.Background(), keys)
+	if err != nil {
+		t.Fatal("Error building paged result: ", err)
+	}
+	if res.slice.Count != 7 || res.pageRows != 1 {
+		t.Error("Unexpected page size: ", res.slice.Count, " columns and ", res.pageRows, " rows")
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	m1, err := NewMapping(&ReasonableOne{})
+	if err != nil {
+		t.Fatal("Error building mapping:", err)
+	}
+
+	keys := []interface{}{"streamuser1", "nope", "streamuser2"}
+	expected := make([]*ReasonableOne, 0)
+
+	w := cp.Writer()
+	for _, key := range keys {
+		w.Delete("ReasonableOne", []byte(key.(string)))
+	}
+	if err = w.Run(); err != nil {
+		t.Fatal("Error writing:", err)
+	}
+
+	w = cp.Writer()
+	for _, key := range []string{"streamuser1", "streamuser2"} {
+		for i := 0; i < 50; i++ {
+			r := &ReasonableOne{
+				Username: key,
+				TweetID:  int64(100000000000000) + int64(i),
+				Lat:      1.00002,
+				Lon:      -38.11,
+				Body:     "hey this thing appears to work, nice!",
+			}
+			row, err := m1.Map(r)
+			if err != nil {
+				t.Fatal("Error mapping:", err)
+			}
+			w.InsertTtl("ReasonableOne", row, 60)
+			expected = append(expected, r)
+		}
+	}
+	if err = w.Run(); err != nil {
+		t.Fatal("Error writing:", err)
+	}
+
+	// 7 columns and 1 row per page forces objects to be split between pages
+	r1 := &ReasonableOne{}
+	i := 0
+	err = cp.Query(m1).PageSize(7, 1).Each(context.Background(), keys, r1, func() error {
+		if i >= len(expected) {
+			t.Fatal("Each returned more objects than expected")
+		}
+		if !reflect.DeepEqual(r1, expected[i]) {
+			t.Error("Read does not match Write in position ", i, ": ", r1)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Each error:", err)
+	}
+	if i != len(expected) {
+		t.Error("Each returned ", i, " objects, expected ", len(expected))
+	}
+
+	stop := errors.New("stop")
+	i = 0
+	err = cp.Query(m1).PageSize(7, 1).Each(context.Background(), keys, r1, func() error {
+		i++
+		if i == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || i != 10 {
+		t.Error("Each did not stop with the callback error: ", err, " after ", i, " objects")
+	}
+
+	objects, errs := cp.Query(m1).PageSize(7, 1).Stream(context.Background(), keys, &ReasonableOne{})
+	i = 0
+	for o := range objects {
+		if !reflect.DeepEqual(o, expected[i]) {
+			t.Error("Streamed object does not match Write in position ", i, ": ", o)
+		}
+		i++
+	}
+	if err := <-errs; err != nil {
+		t.Error("Stream error:", err)
+	}
+	if i != len(expected) {
+		t.Error("Stream returned ", i, " objects, expected ", len(expected))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	objects, errs = cp.Query(m1).PageSize(7, 1).Stream(ctx, keys, &ReasonableOne{})
+	for i := 0; i < 5; i++ {
+		<-objects
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Error("Stream was not cancelled: ", err)
+	}
+}
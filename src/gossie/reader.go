@@ -1,17 +1,24 @@
 package gossie
 
 import (
+	"bytes"
 	"errors"
 	"github.com/carloscm/gossie/src/cassandra"
 	"github.com/pomack/thrift4go/lib/go/src/thrift"
+	"sync"
 )
 
 /*
 	to do:
 	support Where for RangeGet in Cassandra 1.1
-	figure out what's the deal with get_paged_slice in 1.1 and try to implement it in a sane way
+	use the native get_paged_slice for PagedRangeGet once the Thrift bindings are updated to 1.1
 */
 
+var (
+	ErrorNotFound   = errors.New("Column not found")
+	ErrorNotCounter = errors.New("Column is not a counter")
+)
+
 // Columns encapsulate the individual columns from/to Cassandra reads and writes
 type Column struct {
 	Name      []byte
@@ -20,12 +27,32 @@ type Column struct {
 	Timestamp int64
 }
 
-// Row is a Cassandra row, including its row key
-type Row struct {
-	Key     []byte
+// SuperColumn is a named group of columns inside a row of a super column family
+type SuperColumn struct {
+	Name    []byte
 	Columns []*Column
 }
 
+// Row is a Cassandra row, including its row key. Rows read from super column families without
+// selecting a super column have their data in SuperColumns instead of Columns
+type Row struct {
+	Key          []byte
+	Columns      []*Column
+	SuperColumns []*SuperColumn
+}
+
+// Counter is a counter column and its current value
+type Counter struct {
+	Name  []byte
+	Value int64
+}
+
+// CounterRow is a row of counter columns, including its row key
+type CounterRow struct {
+	Key      []byte
+	Counters []*Counter
+}
+
 // RowColumnCount stores the number of columns matched in a MultiCount reader
 type RowColumnCount struct {
 	Key   []byte
@@ -85,6 +112,11 @@ type Reader interface {
 	// This method must be always called.
 	Cf(string) Reader
 
+	// SuperColumn optionally selects a super column by name for reads over a super column family.
+	// When selected the reads work over the columns inside the super column, otherwise the returned
+	// rows are filled with super columns.
+	SuperColumn([]byte) Reader
+
 	// Slice optionally sets a slice to set a range of column names and potentially iterate over the
 	// columns of the returned row(s)
 	Slice(*Slice) Reader
@@ -92,6 +124,16 @@ type Reader interface {
 	// Columns optionally filters the returned columns to only the passed set of column names
 	Columns([][]byte) Reader
 
+	// Chunks optionally splits the keys passed to MultiGet, MultiCount and MultiExists in batches of
+	// up to size keys. Up to concurrency batches are run at the same time, each one over its own pool
+	// connection, and their results are merged. By default all the keys are sent in a single call
+	Chunks(size, concurrency int) Reader
+
+	// KeyOrder set to true makes MultiGet and MultiCount return exactly one entry per passed key, in
+	// the same order as the keys. Missing rows are returned as a Row with no columns and a
+	// RowColumnCount with a Count of 0. By default missing rows are skipped and the order is undefined
+	KeyOrder(bool) Reader
+
 	// Each call to this method adds a new comparison to be checked against the returned rows of
 	// IndexedGet
 	// All the comparisons are checked for every row. In the current Cassandra implementation at
@@ -101,11 +143,33 @@ type Reader interface {
 	// Get looks up a row with the given key and returns it, or nil in case it is not found
 	Get(key []byte) (*Row, error)
 
+	// GetColumn looks up a single column by name in the row with the given key. It returns
+	// ErrorNotFound in case the row or the column do not exist
+	GetColumn(key []byte, name []byte) (*Column, error)
+
+	// Exists checks if the row with the given key has at least one column, reading at most one
+	// column instead of the full row. Slice and Columns are honored to restrict the checked columns
+	Exists(key []byte) (bool, error)
+
+	// MultiExists performs a parallel Exists operation for all the passed keys, and returns a map
+	// with an entry for every passed key (as a string) set to true if the row exists. It returns nil
+	// only on error conditions
+	MultiExists(keys [][]byte) (map[string]bool, error)
+
 	// MultiGet performs a parallel Get operation for all the passed keys, and returns a slice of
 	// RowColumnCounts pointers to the gathered rows, which may be empty if none were found. It returns
 	// nil only on error conditions
 	MultiGet(keys [][]byte) ([]*Row, error)
 
+	// GetCounter looks up a single counter column by name in the row with the given key and returns
+	// its value. It returns ErrorNotFound in case the row or the column do not exist, and
+	// ErrorNotCounter if the column family does not store counters
+	GetCounter(key []byte, name []byte) (int64, error)
+
+	// MultiGetCounters works like MultiGet for column families storing counters, returning the
+	// counter values as int64
+	MultiGetCounters(keys [][]byte) ([]*CounterRow, error)
+
 	// Count looks up a row with the given key and returns the number of columns it has
 	Count(key []byte) (int, error)
 
@@ -119,6 +183,14 @@ type Reader interface {
 	// may be empty if none were found. It returns nil only on error conditions
 	RangeGet(*Range) ([]*Row, error)
 
+	// PagedRangeGet iterates over a range of rows and their columns as a single continuous stream of
+	// columns. Every call to RangePager.Next returns up to columns columns in total, continuing inside
+	// the current row before moving to the next one, so wide rows are split between pages. The Count
+	// of the passed Range sets how many rows are read per underlying request. Slice is honored but
+	// Columns is not supported. It emulates the get_paged_slice call from Cassandra 1.1 with range and
+	// slice reads, since it is not available in the Thrift bindings used by Gossie.
+	PagedRangeGet(rang *Range, columns int) (RangePager, error)
+
 	// IndexedGet performs a sequential Get operation for a range of rows and returns only those that match
 	// the Where clauses. See the docs for Range for an explanation on how to page results. It returns a
 	// slice of Row pointers to the gathered rows, which may be empty if none were found. It returns nil only
@@ -126,16 +198,29 @@ type Reader interface {
 	IndexedGet(*IndexedRange) ([]*Row, error)
 }
 
+// RangePager iterates over the pages of a Reader.PagedRangeGet call
+type RangePager interface {
+
+	// Next returns the next page of columns grouped in rows. The same row key may appear in
+	// consecutive pages when its columns are split between them. It returns Done when all the
+	// rows in the range have been read.
+	Next() ([]*Row, error)
+}
+
 type reader struct {
 	pool             *connectionPool
 	consistencyLevel int
 	cf               string
+	superColumn      []byte
 	slice            Slice
 	setSlice         bool
 	columns          [][]byte
 	setColumns       bool
 	setWhere         bool
 	expressions      thrift.TList
+	chunkSize        int
+	chunkConcurrency int
+	keyOrder         bool
 }
 
 func newReader(cp *connectionPool, cl int) *reader {
@@ -155,6 +240,11 @@ func (r *reader) Cf(cf string) Reader {
 	return r
 }
 
+func (r *reader) SuperColumn(name []byte) Reader {
+	r.superColumn = name
+	return r
+}
+
 func (r *reader) Slice(s *Slice) Reader {
 	r.slice = *s
 	r.setSlice = true
@@ -168,6 +258,17 @@ func (r *reader) Columns(c [][]byte) Reader {
 	return r
 }
 
+func (r *reader) Chunks(size, concurrency int) Reader {
+	r.chunkSize = size
+	r.chunkConcurrency = concurrency
+	return r
+}
+
+func (r *reader) KeyOrder(o bool) Reader {
+	r.keyOrder = o
+	return r
+}
+
 func (r *reader) Where(column []byte, op Operator, value []byte) Reader {
 	if r.expressions == nil {
 		r.expressions = thrift.NewTList(thrift.STRUCT, 1)
@@ -220,9 +321,21 @@ func (r *reader) buildPredicate() *cassandra.SlicePredicate {
 	return sp
 }
 
+// buildExistsPredicate builds the same predicate as buildPredicate but limited to a single column
+func (r *reader) buildExistsPredicate() *cassandra.SlicePredicate {
+	sp := r.buildPredicate()
+	if sp.SliceRange != nil {
+		sp.SliceRange.Count = 1
+	}
+	return sp
+}
+
 func (r *reader) buildColumnParent() *cassandra.ColumnParent {
 	cp := cassandra.NewColumnParent()
 	cp.ColumnFamily = r.cf
+	if r.superColumn != nil {
+		cp.SuperColumn = r.superColumn
+	}
 	return cp
 }
 
@@ -279,6 +392,132 @@ func (r *reader) Get(key []byte) (*Row, error) {
 	return rowFromTListColumns(key, ret), nil
 }
 
+func (r *reader) getColumn(key []byte, name []byte) (*cassandra.ColumnOrSuperColumn, error) {
+	if r.cf == "" {
+		return nil, errors.New("No column family specified")
+	}
+
+	cp := cassandra.NewColumnPath()
+	cp.ColumnFamily = r.cf
+	cp.Column = name
+	if r.superColumn != nil {
+		cp.SuperColumn = r.superColumn
+	}
+
+	var ret *cassandra.ColumnOrSuperColumn
+	var notFound bool
+	err := r.pool.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var nfe *cassandra.NotFoundException
+		var ue *cassandra.UnavailableException
+		var te *cassandra.TimedOutException
+		var err error
+		ret, ire, nfe, ue, te, err = c.client.Get(
+			key, cp, cassandra.ConsistencyLevel(r.consistencyLevel))
+		notFound = nfe != nil
+		return &transactionError{ire, ue, te, err}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if notFound || ret == nil {
+		return nil, ErrorNotFound
+	}
+
+	return ret, nil
+}
+
+func (r *reader) GetColumn(key []byte, name []byte) (*Column, error) {
+	ret, err := r.getColumn(key, name)
+	if err != nil {
+		return nil, err
+	}
+
+	col := columnFromTColumnOrSuperColumn(ret)
+	if col == nil {
+		return nil, ErrorNotFound
+	}
+
+	return col, nil
+}
+
+func (r *reader) GetCounter(key []byte, name []byte) (int64, error) {
+	ret, err := r.getColumn(key, name)
+	if err != nil {
+		return 0, err
+	}
+	if ret.CounterColumn == nil {
+		return 0, ErrorNotCounter
+	}
+	return ret.CounterColumn.Value, nil
+}
+
+func (r *reader) MultiGetCounters(keys [][]byte) ([]*CounterRow, error) {
+	if r.cf == "" {
+		return nil, errors.New("No column family specified")
+	}
+
+	if len(keys) <= 0 {
+		return make([]*CounterRow, 0), nil
+	}
+
+	cp := r.buildColumnParent()
+	sp := r.buildPredicate()
+
+	results := make([][]*CounterRow, len(chunkKeys(keys, r.chunkSize)))
+	err := r.runChunks(keys, func(i int, chunk [][]byte) error {
+		ret, err := r.multigetSlice(chunk, cp, sp)
+		if err != nil {
+			return err
+		}
+		results[i], err = counterRowsFromTMap(ret)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	counterRows := make([]*CounterRow, 0)
+	for _, chunkRows := range results {
+		counterRows = append(counterRows, chunkRows...)
+	}
+
+	if r.keyOrder {
+		return orderCounterRows(keys, counterRows), nil
+	}
+
+	return counterRows, nil
+}
+
+func (r *reader) Exists(key []byte) (bool, error) {
+	if r.cf == "" {
+		return false, errors.New("No column family specified")
+	}
+
+	cp := r.buildColumnParent()
+	sp := r.buildExistsPredicate()
+
+	var ret thrift.TList
+	err := r.pool.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var ue *cassandra.UnavailableException
+		var te *cassandra.TimedOutException
+		var err error
+		ret, ire, ue, te, err = c.client.GetSlice(
+			key, cp, sp, cassandra.ConsistencyLevel(r.consistencyLevel))
+		return &transactionError{ire, ue, te, err}
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return ret != nil && ret.Len() > 0, nil
+}
+
 func (r *reader) Count(key []byte) (int, error) {
 	if r.cf == "" {
 		return 0, errors.New("No column family specified")
@@ -313,17 +552,66 @@ func (r *reader) buildMultiKeys(keys [][]byte) thrift.TList {
 	return tkeys
 }
 
-func (r *reader) MultiGet(keys [][]byte) ([]*Row, error) {
-	if r.cf == "" {
-		return nil, errors.New("No column family specified")
+// chunkKeys splits keys in chunks of up to size keys, or returns a single chunk if size is 0
+func chunkKeys(keys [][]byte, size int) [][][]byte {
+	if size <= 0 || size > len(keys) {
+		size = len(keys)
 	}
+	chunks := make([][][]byte, 0, (len(keys)+size-1)/size)
+	for len(keys) > 0 {
+		n := size
+		if n > len(keys) {
+			n = len(keys)
+		}
+		chunks = append(chunks, keys[:n])
+		keys = keys[n:]
+	}
+	return chunks
+}
 
-	if len(keys) <= 0 {
-		return make([]*Row, 0), nil
+// runChunks calls f for every chunk of keys, running up to the configured concurrency at once. Each
+// call to f gets the chunk index so results can be stored without further locking. It returns the
+// first error found, if any
+func (r *reader) runChunks(keys [][]byte, f func(i int, chunk [][]byte) error) error {
+	chunks := chunkKeys(keys, r.chunkSize)
+	if len(chunks) == 1 {
+		return f(0, chunks[0])
 	}
 
-	cp := r.buildColumnParent()
-	sp := r.buildPredicate()
+	concurrency := r.chunkConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	slots := make(chan bool, concurrency)
+	errs := make(chan error, len(chunks))
+	var wg sync.WaitGroup
+
+	for i, chunk := range chunks {
+		slots <- true
+		// stop sending new chunks once one of them failed
+		if len(errs) > 0 {
+			<-slots
+			break
+		}
+		wg.Add(1)
+		go func(i int, chunk [][]byte) {
+			defer wg.Done()
+			if err := f(i, chunk); err != nil {
+				errs <- err
+			}
+			<-slots
+		}(i, chunk)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// a closed and empty channel returns nil
+	return <-errs
+}
+
+func (r *reader) multigetSlice(keys [][]byte, cp *cassandra.ColumnParent, sp *cassandra.SlicePredicate) (thrift.TMap, error) {
 	tk := r.buildMultiKeys(keys)
 
 	var ret thrift.TMap
@@ -337,57 +625,143 @@ func (r *reader) MultiGet(keys [][]byte) ([]*Row, error) {
 		return &transactionError{ire, ue, te, err}
 	})
 
+	return ret, err
+}
+
+func (r *reader) multigetCount(keys [][]byte, cp *cassandra.ColumnParent, sp *cassandra.SlicePredicate) (thrift.TMap, error) {
+	tk := r.buildMultiKeys(keys)
+
+	var ret thrift.TMap
+	err := r.pool.run(func(c *connection) *transactionError {
+		var ire *cassandra.InvalidRequestException
+		var ue *cassandra.UnavailableException
+		var te *cassandra.TimedOutException
+		var err error
+		ret, ire, ue, te, err = c.client.MultigetCount(
+			tk, cp, sp, cassandra.ConsistencyLevel(r.consistencyLevel))
+		return &transactionError{ire, ue, te, err}
+	})
+
+	return ret, err
+}
+
+func (r *reader) MultiGet(keys [][]byte) ([]*Row, error) {
+	if r.cf == "" {
+		return nil, errors.New("No column family specified")
+	}
+
+	if len(keys) <= 0 {
+		return make([]*Row, 0), nil
+	}
+
+	cp := r.buildColumnParent()
+	sp := r.buildPredicate()
+
+	results := make([][]*Row, len(chunkKeys(keys, r.chunkSize)))
+	err := r.runChunks(keys, func(i int, chunk [][]byte) error {
+		ret, err := r.multigetSlice(chunk, cp, sp)
+		if err != nil {
+			return err
+		}
+		results[i] = rowsFromTMap(ret)
+		return nil
+	})
+
 	if err != nil {
 		return nil, err
 	}
 
-	return rowsFromTMap(ret), nil
+	rows := make([]*Row, 0)
+	for _, chunkRows := range results {
+		rows = append(rows, chunkRows...)
+	}
+
+	if r.keyOrder {
+		return orderRows(keys, rows), nil
+	}
+
+	return rows, nil
 }
 
-func (r *reader) MultiCount(keys [][]byte) ([]*RowColumnCount, error) {
+func (r *reader) MultiExists(keys [][]byte) (map[string]bool, error) {
 	if r.cf == "" {
 		return nil, errors.New("No column family specified")
 	}
 
+	exists := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		exists[string(key)] = false
+	}
+
 	if len(keys) <= 0 {
-		return make([]*RowColumnCount, 0), nil
+		return exists, nil
 	}
 
 	cp := r.buildColumnParent()
-	sp := r.buildPredicate()
-	tk := r.buildMultiKeys(keys)
+	sp := r.buildExistsPredicate()
 
-	var ret thrift.TMap
-	err := r.pool.run(func(c *connection) *transactionError {
-		var ire *cassandra.InvalidRequestException
-		var ue *cassandra.UnavailableException
-		var te *cassandra.TimedOutException
-		var err error
-		ret, ire, ue, te, err = c.client.MultigetCount(
-			tk, cp, sp, cassandra.ConsistencyLevel(r.consistencyLevel))
-		return &transactionError{ire, ue, te, err}
+	results := make([][]*Row, len(chunkKeys(keys, r.chunkSize)))
+	err := r.runChunks(keys, func(i int, chunk [][]byte) error {
+		ret, err := r.multigetSlice(chunk, cp, sp)
+		if err != nil {
+			return err
+		}
+		results[i] = rowsFromTMap(ret)
+		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return rowsColumnCountFromTMap(ret), nil
+	for _, chunkRows := range results {
+		for _, row := range chunkRows {
+			exists[string(row.Key)] = true
+		}
+	}
+
+	return exists, nil
 }
 
-func (r *reader) RangeGet(rang *Range) ([]*Row, error) {
+func (r *reader) MultiCount(keys [][]byte) ([]*RowColumnCount, error) {
 	if r.cf == "" {
 		return nil, errors.New("No column family specified")
 	}
 
-	if rang == nil || rang.Count <= 0 {
-		return make([]*Row, 0), nil
+	if len(keys) <= 0 {
+		return make([]*RowColumnCount, 0), nil
 	}
 
-	kr := r.buildKeyRange(rang)
 	cp := r.buildColumnParent()
 	sp := r.buildPredicate()
 
+	results := make([][]*RowColumnCount, len(chunkKeys(keys, r.chunkSize)))
+	err := r.runChunks(keys, func(i int, chunk [][]byte) error {
+		ret, err := r.multigetCount(chunk, cp, sp)
+		if err != nil {
+			return err
+		}
+		results[i] = rowsColumnCountFromTMap(ret)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]*RowColumnCount, 0)
+	for _, chunkCounts := range results {
+		counts = append(counts, chunkCounts...)
+	}
+
+	if r.keyOrder {
+		return orderRowColumnCounts(keys, counts), nil
+	}
+
+	return counts, nil
+}
+
+func (r *reader) getRangeSlices(kr *cassandra.KeyRange, cp *cassandra.ColumnParent, sp *cassandra.SlicePredicate) (thrift.TList, error) {
 	var ret thrift.TList
 	err := r.pool.run(func(c *connection) *transactionError {
 		var ire *cassandra.InvalidRequestException
@@ -398,6 +772,23 @@ func (r *reader) RangeGet(rang *Range) ([]*Row, error) {
 			cp, sp, kr, cassandra.ConsistencyLevel(r.consistencyLevel))
 		return &transactionError{ire, ue, te, err}
 	})
+	return ret, err
+}
+
+func (r *reader) RangeGet(rang *Range) ([]*Row, error) {
+	if r.cf == "" {
+		return nil, errors.New("No column family specified")
+	}
+
+	if rang == nil || rang.Count <= 0 {
+		return make([]*Row, 0), nil
+	}
+
+	kr := r.buildKeyRange(rang)
+	cp := r.buildColumnParent()
+	sp := r.buildPredicate()
+
+	ret, err := r.getRangeSlices(kr, cp, sp)
 
 	if err != nil {
 		return nil, err
@@ -406,6 +797,37 @@ func (r *reader) RangeGet(rang *Range) ([]*Row, error) {
 	return rowsFromTListKeySlice(ret), nil
 }
 
+func (r *reader) PagedRangeGet(rang *Range, columns int) (RangePager, error) {
+	if r.cf == "" {
+		return nil, errors.New("No column family specified")
+	}
+
+	if r.setColumns {
+		return nil, errors.New("Columns cannot be used with PagedRangeGet, use Slice instead")
+	}
+
+	if columns <= 0 {
+		return nil, errors.New("The number of columns per page must be greater than 0")
+	}
+
+	p := &rangePager{
+		reader:  *r,
+		columns: columns,
+	}
+	if r.setSlice {
+		p.slice = r.slice
+	}
+	if rang == nil || rang.Count <= 0 {
+		p.done = true
+	} else {
+		p.start = rang.Start
+		p.end = rang.End
+		p.rows = rang.Count
+	}
+
+	return p, nil
+}
+
 func (r *reader) IndexedGet(rang *IndexedRange) ([]*Row, error) {
 	if r.cf == "" {
 		return nil, errors.New("No column family specified")
@@ -448,26 +870,65 @@ func rowFromTListColumns(key []byte, tl thrift.TList) *Row {
 	r := &Row{Key: key}
 	for colI := range tl.Iter() {
 		var col *cassandra.ColumnOrSuperColumn = colI.(*cassandra.ColumnOrSuperColumn)
-		if col.Column != nil {
-			c := &Column{
-				Name:      col.Column.Name,
-				Value:     col.Column.Value,
-				Timestamp: col.Column.Timestamp,
-				Ttl:       col.Column.Ttl,
-			}
-			r.Columns = append(r.Columns, c)
-		} else if col.CounterColumn != nil {
-			v, _ := Marshal(col.CounterColumn.Value, LongType)
-			c := &Column{
-				Name:  col.CounterColumn.Name,
-				Value: v,
-			}
+		if c := columnFromTColumnOrSuperColumn(col); c != nil {
 			r.Columns = append(r.Columns, c)
+		} else if sc := superColumnFromTColumnOrSuperColumn(col); sc != nil {
+			r.SuperColumns = append(r.SuperColumns, sc)
 		}
 	}
 	return r
 }
 
+func superColumnFromTColumnOrSuperColumn(col *cassandra.ColumnOrSuperColumn) *SuperColumn {
+	if col.SuperColumn != nil {
+		sc := &SuperColumn{Name: col.SuperColumn.Name}
+		if col.SuperColumn.Columns != nil {
+			for colI := range col.SuperColumn.Columns.Iter() {
+				c := colI.(*cassandra.Column)
+				sc.Columns = append(sc.Columns, &Column{
+					Name:      c.Name,
+					Value:     c.Value,
+					Timestamp: c.Timestamp,
+					Ttl:       c.Ttl,
+				})
+			}
+		}
+		return sc
+	} else if col.CounterSuperColumn != nil {
+		sc := &SuperColumn{Name: col.CounterSuperColumn.Name}
+		if col.CounterSuperColumn.Columns != nil {
+			for colI := range col.CounterSuperColumn.Columns.Iter() {
+				c := colI.(*cassandra.CounterColumn)
+				v, _ := Marshal(c.Value, LongType)
+				sc.Columns = append(sc.Columns, &Column{
+					Name:  c.Name,
+					Value: v,
+				})
+			}
+		}
+		return sc
+	}
+	return nil
+}
+
+func columnFromTColumnOrSuperColumn(col *cassandra.ColumnOrSuperColumn) *Column {
+	if col.Column != nil {
+		return &Column{
+			Name:      col.Column.Name,
+			Value:     col.Column.Value,
+			Timestamp: col.Column.Timestamp,
+			Ttl:       col.Column.Ttl,
+		}
+	} else if col.CounterColumn != nil {
+		v, _ := Marshal(col.CounterColumn.Value, LongType)
+		return &Column{
+			Name:  col.CounterColumn.Name,
+			Value: v,
+		}
+	}
+	return nil
+}
+
 func keyFromTMap(e thrift.TMapElem) []byte {
 	// workaround some issues with the way the key->row array gets built by thrift4go and
 	// the cassandra IDL wrongly insisting keys are strings
@@ -498,6 +959,32 @@ func rowsFromTMap(tm thrift.TMap) []*Row {
 	return r
 }
 
+// counterRowsFromTMap reads the counter columns of a multiget_slice result. It returns
+// ErrorNotCounter if any of the columns is not a counter column.
+func counterRowsFromTMap(tm thrift.TMap) ([]*CounterRow, error) {
+	if tm == nil || tm.Len() <= 0 {
+		return make([]*CounterRow, 0), nil
+	}
+	r := make([]*CounterRow, 0)
+	for rowI := range tm.Iter() {
+		key := keyFromTMap(rowI)
+		columns := (rowI.Value()).(thrift.TList)
+		if columns == nil || columns.Len() <= 0 {
+			continue
+		}
+		row := &CounterRow{Key: key}
+		for colI := range columns.Iter() {
+			col := colI.(*cassandra.ColumnOrSuperColumn)
+			if col.CounterColumn == nil {
+				return nil, ErrorNotCounter
+			}
+			row.Counters = append(row.Counters, &Counter{Name: col.CounterColumn.Name, Value: col.CounterColumn.Value})
+		}
+		r = append(r, row)
+	}
+	return r, nil
+}
+
 func rowsColumnCountFromTMap(tm thrift.TMap) []*RowColumnCount {
 	if tm == nil || tm.Len() <= 0 {
 		return make([]*RowColumnCount, 0)
@@ -513,6 +1000,60 @@ func rowsColumnCountFromTMap(tm thrift.TMap) []*RowColumnCount {
 	return r
 }
 
+// orderRows returns one row per passed key in the same order as keys, using an empty row for the
+// keys not present in rows
+func orderRows(keys [][]byte, rows []*Row) []*Row {
+	byKey := make(map[string]*Row, len(rows))
+	for _, row := range rows {
+		byKey[string(row.Key)] = row
+	}
+	r := make([]*Row, 0, len(keys))
+	for _, key := range keys {
+		row, found := byKey[string(key)]
+		if !found {
+			row = &Row{Key: key}
+		}
+		r = append(r, row)
+	}
+	return r
+}
+
+// orderCounterRows returns one counter row per passed key in the same order as keys, using an
+// empty row for the keys not present in rows
+func orderCounterRows(keys [][]byte, rows []*CounterRow) []*CounterRow {
+	byKey := make(map[string]*CounterRow, len(rows))
+	for _, row := range rows {
+		byKey[string(row.Key)] = row
+	}
+	r := make([]*CounterRow, 0, len(keys))
+	for _, key := range keys {
+		row, found := byKey[string(key)]
+		if !found {
+			row = &CounterRow{Key: key}
+		}
+		r = append(r, row)
+	}
+	return r
+}
+
+// orderRowColumnCounts returns one count per passed key in the same order as keys, using a zero count
+// for the keys not present in counts
+func orderRowColumnCounts(keys [][]byte, counts []*RowColumnCount) []*RowColumnCount {
+	byKey := make(map[string]*RowColumnCount, len(counts))
+	for _, count := range counts {
+		byKey[string(count.Key)] = count
+	}
+	r := make([]*RowColumnCount, 0, len(keys))
+	for _, key := range keys {
+		count, found := byKey[string(key)]
+		if !found {
+			count = &RowColumnCount{Key: key}
+		}
+		r = append(r, count)
+	}
+	return r
+}
+
 func rowsFromTListKeySlice(tl thrift.TList) []*Row {
 	if tl == nil || tl.Len() <= 0 {
 		return make([]*Row, 0)
@@ -529,6 +1070,16 @@ func rowsFromTListKeySlice(tl thrift.TList) []*Row {
 	return r
 }
 
+// SuperColumn returns the super column with the passed name, or nil in case it is not present in the row
+func (r *Row) SuperColumn(name []byte) *SuperColumn {
+	for _, sc := range r.SuperColumns {
+		if bytes.Equal(sc.Name, name) {
+			return sc
+		}
+	}
+	return nil
+}
+
 func (r *Row) ColumnNames() [][]byte {
 	names := [][]byte{}
 	for _, col := range r.Columns {
@@ -536,3 +1087,149 @@ func (r *Row) ColumnNames() [][]byte {
 	}
 	return names
 }
+
+type rangePager struct {
+	reader  reader
+	slice   Slice
+	columns int
+	rows    int
+	start   []byte
+	end     []byte
+	// the row at start was already completely read
+	skip bool
+	// the row at start was partially read up to this column name
+	column []byte
+	done   bool
+}
+
+func (p *rangePager) Next() ([]*Row, error) {
+	if p.done {
+		return nil, Done
+	}
+
+	page := make([]*Row, 0)
+	remaining := p.columns
+
+	for remaining > 0 && !p.done {
+		var err error
+		if p.column != nil {
+			remaining, err = p.continueRow(&page, remaining)
+		} else {
+			remaining, err = p.nextRows(&page, remaining)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(page) <= 0 {
+		return nil, Done
+	}
+
+	return page, nil
+}
+
+// continueRow reads more columns from the partially read row at the start key
+func (p *rangePager) continueRow(page *[]*Row, remaining int) (int, error) {
+	slice := p.slice
+	slice.Start = p.column
+	// the slice start is inclusive so read one more column to make up for the already read one
+	slice.Count = remaining + 1
+
+	r := p.reader
+	row, err := r.Slice(&slice).Get(p.start)
+	if err != nil {
+		return remaining, err
+	}
+
+	var columns []*Column
+	if row != nil {
+		columns = row.Columns
+	}
+	full := len(columns) >= slice.Count
+	if len(columns) > 0 && bytes.Equal(columns[0].Name, p.column) {
+		columns = columns[1:]
+	}
+	if len(columns) > remaining {
+		columns = columns[:remaining]
+	}
+
+	if len(columns) > 0 {
+		*page = append(*page, &Row{Key: p.start, Columns: columns})
+		remaining -= len(columns)
+	}
+
+	if full && len(columns) > 0 {
+		p.column = columns[len(columns)-1].Name
+	} else {
+		p.column = nil
+		p.skip = true
+	}
+
+	return remaining, nil
+}
+
+// nextRows reads a batch of rows starting at the start key, filling the page with their columns up
+// to remaining columns
+func (p *rangePager) nextRows(page *[]*Row, remaining int) (int, error) {
+	requested := p.rows
+	if p.skip {
+		requested++
+	}
+
+	slice := p.slice
+	slice.Count = remaining
+
+	r := &p.reader
+	kr := r.buildKeyRange(&Range{Start: p.start, End: p.end, Count: requested})
+	cp := r.buildColumnParent()
+	sp := cassandra.NewSlicePredicate()
+	sp.SliceRange = sliceToCassandra(&slice)
+
+	ret, err := r.getRangeSlices(kr, cp, sp)
+	if err != nil {
+		return remaining, err
+	}
+
+	if ret == nil {
+		p.done = true
+		return remaining, nil
+	}
+
+	read := ret.Len()
+	var last []byte
+	for keySliceI := range ret.Iter() {
+		keySlice := keySliceI.(*cassandra.KeySlice)
+		key := keySlice.Key
+		if p.skip && bytes.Equal(key, p.start) {
+			continue
+		}
+		last = key
+
+		row := rowFromTListColumns(key, keySlice.Columns)
+		// range ghosts and rows without columns in the slice
+		if row == nil || len(row.Columns) <= 0 {
+			continue
+		}
+
+		*page = append(*page, row)
+		if len(row.Columns) >= remaining {
+			// the row may have more columns than the ones read, continue inside it in the next step
+			p.start = key
+			p.column = row.Columns[len(row.Columns)-1].Name
+			p.skip = false
+			return 0, nil
+		}
+		remaining -= len(row.Columns)
+	}
+
+	if last != nil {
+		p.start = last
+		p.skip = true
+	}
+	if read < requested || last == nil {
+		p.done = true
+	}
+
+	return remaining, nil
+}
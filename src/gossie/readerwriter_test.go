@@ -2,8 +2,10 @@ package gossie
 
 import (
 	"fmt"
+	"github.com/carloscm/gossie/src/cassandra"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type testColumn struct {
@@ -251,6 +253,48 @@ func TestWriterAndReader(t *testing.T) {
 		t.Error("A row had an unexpected column count ", count)
 	}
 
+	col, err := cp.Reader().Cf("AllTypes").GetColumn([]byte("row2"), []byte("colUTF8Type"))
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if col == nil || string(col.Value) != "leña al fuego" {
+		t.Error("Unexpected column returned by GetColumn: ", col)
+	}
+
+	col, err = cp.Reader().Cf("AllTypes").GetColumn([]byte("row1"), []byte("colAsciiType"))
+	if err != ErrorNotFound {
+		t.Error("Expected ErrorNotFound for a deleted column but got: ", err)
+	}
+
+	col, err = cp.Reader().Cf("AllTypes").GetColumn([]byte("rowNo"), []byte("colAsciiType"))
+	if err != ErrorNotFound {
+		t.Error("Expected ErrorNotFound for an unexisting row but got: ", err)
+	}
+
+	exists, err := cp.Reader().Cf("AllTypes").Exists([]byte("row2"))
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if !exists {
+		t.Error("An expected row was reported as unexisting")
+	}
+
+	exists, err = cp.Reader().Cf("AllTypes").Exists([]byte("row0"))
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if exists {
+		t.Error("An expected deleted row was reported as existing")
+	}
+
+	existsMap, err := cp.Reader().Cf("AllTypes").MultiExists([][]byte{[]byte("row0"), []byte("row1"), []byte("row2")})
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if len(existsMap) != 3 || existsMap["row0"] || !existsMap["row1"] || !existsMap["row2"] {
+		t.Error("Unexpected result in MultiExists call: ", existsMap)
+	}
+
 	rows, err := cp.Reader().Cf("AllTypes").MultiGet([][]byte{[]byte("rowNo1"), []byte("rowNo2"), []byte("rowNo3")})
 	if err != nil {
 		t.Error("Error running query: ", err)
@@ -283,7 +327,28 @@ func TestWriterAndReader(t *testing.T) {
 		}
 	}
 
-	counts, err := cp.Reader().Cf("AllTypes").MultiCount([][]byte{[]byte("row0"), []byte("row1"), []byte("row2")})
+	rows, err = cp.Reader().Cf("AllTypes").Chunks(1, 2).KeyOrder(true).MultiGet([][]byte{[]byte("row2"), []byte("row0"), []byte("row1")})
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if len(rows) != 3 {
+		t.Fatal("Expected 3 rows in ordered MultiGet call, got ", len(rows))
+	}
+	checkRow(t, buildAllTypesTestRow("row2"), rows[0])
+	if string(rows[1].Key) != "row0" || len(rows[1].Columns) != 0 {
+		t.Error("Expected an empty row for a missing key in ordered MultiGet call, got ", rows[1])
+	}
+	checkRow(t, buildAllTypesAfterDeletesTestRow("row1"), rows[2])
+
+	counts, err := cp.Reader().Cf("AllTypes").Chunks(2, 2).KeyOrder(true).MultiCount([][]byte{[]byte("row0"), []byte("row1"), []byte("row2")})
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if len(counts) != 3 || counts[0].Count != 0 || counts[1].Count != 6 || counts[2].Count != 8 {
+		t.Error("Unexpected counts in ordered MultiCount call: ", counts)
+	}
+
+	counts, err = cp.Reader().Cf("AllTypes").MultiCount([][]byte{[]byte("row0"), []byte("row1"), []byte("row2")})
 	if err != nil {
 		t.Error("Error running query: ", err)
 	}
@@ -349,6 +414,353 @@ func TestWriterAndReader(t *testing.T) {
 	cp.Close()
 }
 
+func TestSuperColumns(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	row := &Row{
+		Key: []byte("superrow"),
+		SuperColumns: []*SuperColumn{
+			&SuperColumn{Name: []byte("a"), Columns: []*Column{
+				&Column{Name: []byte("one"), Value: []byte("1")},
+				&Column{Name: []byte("two"), Value: []byte("2")},
+			}},
+			&SuperColumn{Name: []byte("b"), Columns: []*Column{
+				&Column{Name: []byte("three"), Value: []byte("3")},
+			}},
+		},
+	}
+
+	err = cp.Writer().Delete("Super", row.Key).Run()
+	if err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	err = cp.Writer().InsertSuper("Super", row).Run()
+	if err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	read, err := cp.Reader().Cf("Super").Get(row.Key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if read == nil || len(read.SuperColumns) != 2 || len(read.Columns) != 0 {
+		t.Fatal("Unexpected super column row: ", read)
+	}
+	if sc := read.SuperColumn([]byte("a")); sc == nil || len(sc.Columns) != 2 || string(sc.Columns[1].Value) != "2" {
+		t.Error("Unexpected super column a: ", sc)
+	}
+
+	read, err = cp.Reader().Cf("Super").SuperColumn([]byte("b")).Get(row.Key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if read == nil || len(read.Columns) != 1 || string(read.Columns[0].Name) != "three" {
+		t.Error("Unexpected columns for selected super column b: ", read)
+	}
+
+	col, err := cp.Reader().Cf("Super").SuperColumn([]byte("a")).GetColumn(row.Key, []byte("one"))
+	if err != nil {
+		t.Error("Error running query: ", err)
+	}
+	if col == nil || string(col.Value) != "1" {
+		t.Error("Unexpected column returned by GetColumn inside super column a: ", col)
+	}
+
+	err = cp.Writer().DeleteSuper("Super", row.Key, []byte("a")).Run()
+	if err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	read, err = cp.Reader().Cf("Super").Get(row.Key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if read == nil || len(read.SuperColumns) != 1 || string(read.SuperColumns[0].Name) != "b" {
+		t.Error("Unexpected super column row after deleting a super column: ", read)
+	}
+}
+
+func TestPagedRangeGet(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	sizes := map[string]int{"paged0": 25, "paged1": 3, "paged2": 40, "paged3": 7}
+
+	w := cp.Writer()
+	for key := range sizes {
+		w.Delete("ReasonableZero", []byte(key))
+	}
+	if err = w.Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	w = cp.Writer()
+	for key, size := range sizes {
+		row := &Row{Key: []byte(key)}
+		for i := 0; i < size; i++ {
+			row.Columns = append(row.Columns, &Column{Name: []byte(fmt.Sprintf("col%03d", i)), Value: []byte{byte(i)}})
+		}
+		w.InsertTtl("ReasonableZero", row, 60)
+	}
+	if err = w.Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	pager, err := cp.Reader().Cf("ReasonableZero").PagedRangeGet(&Range{Count: 2}, 10)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+
+	read := make(map[string][]*Column)
+	for {
+		rows, err := pager.Next()
+		if err == Done {
+			break
+		}
+		if err != nil {
+			t.Fatal("Error reading page: ", err)
+		}
+		total := 0
+		for _, row := range rows {
+			total += len(row.Columns)
+			read[string(row.Key)] = append(read[string(row.Key)], row.Columns...)
+		}
+		if total > 10 {
+			t.Error("Page returned more columns than requested: ", total)
+		}
+	}
+
+	for key, size := range sizes {
+		columns := read[key]
+		if len(columns) != size {
+			t.Error("Row ", key, " returned ", len(columns), " columns, expected ", size)
+			continue
+		}
+		for i, c := range columns {
+			if string(c.Name) != fmt.Sprintf("col%03d", i) {
+				t.Error("Row ", key, " returned an unexpected column in position ", i, ": ", string(c.Name))
+				break
+			}
+		}
+	}
+}
+
+func TestDeleteSlice(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	key := []byte("deleteslice")
+	row := &Row{Key: key}
+	for i := 0; i < 20; i++ {
+		row.Columns = append(row.Columns, &Column{Name: []byte(fmt.Sprintf("col%03d", i)), Value: []byte{byte(i)}})
+	}
+
+	if err = cp.Writer().Delete("ReasonableZero", key).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	if err = cp.Writer().InsertTtl("ReasonableZero", row, 60).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	// a timestamp older than the columns does not delete anything
+	slice := &Slice{Start: []byte("col005"), End: []byte("col009"), Count: 2}
+	if err = cp.Writer().DeleteSliceAt("ReasonableZero", key, slice, 1).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	count, err := cp.Reader().Cf("ReasonableZero").Count(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if count != 20 {
+		t.Error("Expected 20 columns after deleting a slice in the past, got ", count)
+	}
+
+	if err = cp.Writer().DeleteSlice("ReasonableZero", key, slice).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	read, err := cp.Reader().Cf("ReasonableZero").Get(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if read == nil || len(read.Columns) != 15 {
+		t.Fatal("Expected 15 columns after deleting a slice, got ", read)
+	}
+	for _, c := range read.Columns {
+		if string(c.Name) >= "col005" && string(c.Name) <= "col009" {
+			t.Error("Column in the deleted slice was returned: ", string(c.Name))
+		}
+	}
+}
+
+func TestIsSliceDeletionUnsupported(t *testing.T) {
+	ire := cassandra.NewInvalidRequestException()
+	ire.Why = "Deletion does not yet support SliceRange predicates."
+	if !isSliceDeletionUnsupported(&transactionError{ire: ire}) {
+		t.Error("SliceRange deletion error was not recognized")
+	}
+	ire = cassandra.NewInvalidRequestException()
+	ire.Why = "unconfigured columnfamily"
+	if isSliceDeletionUnsupported(&transactionError{ire: ire}) {
+		t.Error("Unrelated error was recognized as a SliceRange deletion error")
+	}
+	if isSliceDeletionUnsupported(nil) || isSliceDeletionUnsupported(ErrorMaxRetriesReached) {
+		t.Error("Non transaction error was recognized as a SliceRange deletion error")
+	}
+}
+
+func TestWriterTimestamps(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout, Timestamps: NewFixedClock(100)})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	key := []byte("timestamps")
+	row := &Row{Key: key, Columns: []*Column{
+		&Column{Name: []byte("a"), Value: []byte("1")},
+		&Column{Name: []byte("b"), Value: []byte("2")},
+	}}
+
+	if err = cp.Writer().Timestamps(WallClock).Delete("ReasonableZero", key).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	if err = cp.Writer().Timestamps(NewFixedClock(now())).Insert("ReasonableZero", row).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	// older deletions using the pool provider and an explicit timestamp do not delete anything
+	if err = cp.Writer().Delete("ReasonableZero", key).DeleteColumnsAt("ReasonableZero", key, [][]byte{[]byte("a")}, 200).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	count, err := cp.Reader().Cf("ReasonableZero").Count(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if count != 2 {
+		t.Error("Expected 2 columns after deleting in the past, got ", count)
+	}
+
+	if err = cp.Writer().DeleteColumnsAt("ReasonableZero", key, [][]byte{[]byte("a")}, now()).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	count, err = cp.Reader().Cf("ReasonableZero").Count(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if count != 1 {
+		t.Error("Expected 1 column after deleting a column, got ", count)
+	}
+
+	if err = cp.Writer().DeleteAt("ReasonableZero", key, now()).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	count, err = cp.Reader().Cf("ReasonableZero").Count(key)
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if count != 0 {
+		t.Error("Expected 0 columns after deleting the row, got ", count)
+	}
+}
+
+func TestBuildColumnTtl(t *testing.T) {
+	col := &Column{Name: []byte("a"), Value: []byte("1"), Ttl: 60}
+	if c := buildColumn(col, -1, 1); c.Ttl != 60 {
+		t.Error("Expected the column Ttl 60 but got ", c.Ttl)
+	}
+	if c := buildColumn(col, 10, 1); c.Ttl != 10 {
+		t.Error("Expected the passed Ttl 10 to override the column Ttl but got ", c.Ttl)
+	}
+	if c := buildColumn(&Column{Name: []byte("b")}, -1, 1); c.Ttl != 0 {
+		t.Error("Expected no Ttl but got ", c.Ttl)
+	}
+}
+
+func TestWriterTtl(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	key := []byte("ttl")
+	row := &Row{Key: key, Columns: []*Column{&Column{Name: []byte("a"), Value: []byte("1"), Ttl: 60}}}
+	if err = cp.Writer().Insert("ReasonableZero", row).Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+	c, err := cp.Reader().Cf("ReasonableZero").GetColumn(key, []byte("a"))
+	if err != nil {
+		t.Fatal("Error reading column: ", err)
+	}
+	if c.Ttl != 60 {
+		t.Error("Expected the column to be written with Ttl 60 but got ", c.Ttl)
+	}
+}
+
+func TestChunkKeys(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+
+	check := func(size int, expected []int) {
+		chunks := chunkKeys(keys, size)
+		if len(chunks) != len(expected) {
+			t.Fatal("Chunk size ", size, " returned ", len(chunks), " chunks, expected ", len(expected))
+		}
+		var all [][]byte
+		for i, chunk := range chunks {
+			if len(chunk) != expected[i] {
+				t.Error("Chunk size ", size, " returned a chunk of ", len(chunk), " keys in position ", i)
+			}
+			all = append(all, chunk...)
+		}
+		if !reflect.DeepEqual(all, keys) {
+			t.Error("Chunk size ", size, " did not preserve the keys")
+		}
+	}
+
+	check(0, []int{5})
+	check(1, []int{1, 1, 1, 1, 1})
+	check(2, []int{2, 2, 1})
+	check(5, []int{5})
+	check(10, []int{5})
+}
+
+func TestOrderRows(t *testing.T) {
+	keys := [][]byte{[]byte("c"), []byte("a"), []byte("b")}
+	rows := []*Row{
+		&Row{Key: []byte("a"), Columns: []*Column{&Column{Name: []byte("x")}}},
+		&Row{Key: []byte("c"), Columns: []*Column{&Column{Name: []byte("y")}}},
+	}
+
+	ordered := orderRows(keys, rows)
+	if len(ordered) != 3 {
+		t.Fatal("Expected 3 ordered rows, got ", len(ordered))
+	}
+	if ordered[0] != rows[1] || ordered[1] != rows[0] {
+		t.Error("Rows were not returned in key order")
+	}
+	if string(ordered[2].Key) != "b" || len(ordered[2].Columns) != 0 {
+		t.Error("Missing key was not returned as an empty row: ", ordered[2])
+	}
+
+	counts := orderRowColumnCounts(keys, []*RowColumnCount{&RowColumnCount{Key: []byte("b"), Count: 3}})
+	if len(counts) != 3 || counts[0].Count != 0 || counts[1].Count != 0 || counts[2].Count != 3 {
+		t.Error("Counts were not returned in key order")
+	}
+	if string(counts[0].Key) != "c" || string(counts[1].Key) != "a" {
+		t.Error("Missing keys were not returned with their key")
+	}
+}
+
 /*
 func BenchmarkGet(b *testing.B) {
     b.StopTimer()
@@ -374,3 +786,262 @@ func BenchmarkInsert(b *testing.B) {
     }
 }
 */
+
+func TestWriterMutations(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	key := []byte("mutations")
+	w := cp.Writer()
+	w.Insert("ReasonableZero", &Row{Key: key, Columns: []*Column{
+		&Column{Name: []byte("a"), Value: []byte("old"), Timestamp: 10},
+		&Column{Name: []byte("b"), Value: []byte("b"), Timestamp: 10},
+	}})
+	w.Insert("ReasonableZero", &Row{Key: key, Columns: []*Column{
+		&Column{Name: []byte("a"), Value: []byte("new"), Timestamp: 20},
+		&Column{Name: []byte("b"), Value: []byte("older"), Timestamp: 5},
+	}})
+	w.DeltaCounters("Counters", buildCounterRow("mutations"))
+	w.DeltaCounters("Counters", buildCounterRow("mutations"))
+	w.DeleteColumnsAt("ReasonableZero", key, [][]byte{[]byte("c")}, 15)
+
+	ms := w.Mutations()
+	if len(ms) != 3 {
+		t.Fatal("Expected 3 mutations but got ", len(ms), ": ", ms)
+	}
+
+	if ms[0].Kind != InsertMutation || ms[0].Cf != "ReasonableZero" || len(ms[0].Row.Columns) != 2 {
+		t.Fatal("Unexpected insert mutation: ", ms[0])
+	}
+	if string(ms[0].Row.Columns[0].Value) != "new" || ms[0].Row.Columns[0].Timestamp != 20 {
+		t.Error("Newest column write was not kept: ", ms[0])
+	}
+	if string(ms[0].Row.Columns[1].Value) != "b" || ms[0].Row.Columns[1].Timestamp != 10 {
+		t.Error("Older column write was not discarded: ", ms[0])
+	}
+
+	if ms[1].Kind != CounterMutation || len(ms[1].Row.Columns) != 3 {
+		t.Fatal("Unexpected counter mutation: ", ms[1])
+	}
+	if d := buildIntSliceFromRow(ms[1].Row); !reflect.DeepEqual(d, []int64{-84, 2, 2e15}) {
+		t.Error("Counter deltas were not added up: ", d)
+	}
+
+	if ms[2].Kind != DeleteMutation || ms[2].Timestamp != 15 || len(ms[2].Row.Columns) != 1 || string(ms[2].Row.Columns[0].Name) != "c" {
+		t.Error("Unexpected delete mutation: ", ms[2])
+	}
+
+	if err = w.DryRun(); err != nil {
+		t.Error("Unexpected error in dry run: ", err)
+	}
+	if count, _ := cp.Reader().Cf("ReasonableZero").Count(key); count != 0 {
+		t.Error("Dry run sent the mutations, got ", count, " columns")
+	}
+
+	invalid := []Writer{
+		cp.Writer().Insert("NotACf", buildAllTypesRow("dryrun")),
+		cp.Writer().Insert("AllTypes", buildAllTypesRow("")),
+		cp.Writer().Insert("AllTypes", &Row{Key: key, Columns: []*Column{&Column{Name: []byte("colLongType"), Value: []byte{1, 2}}}}),
+		cp.Writer().Insert("Counters", buildCounterRow("dryrun")),
+		cp.Writer().DeltaCounters("AllTypes", buildCounterRow("dryrun")),
+		cp.Writer().DeleteSuper("AllTypes", key, []byte("super")),
+		cp.Writer().InsertSuper("AllTypes", &Row{Key: key, SuperColumns: []*SuperColumn{&SuperColumn{Name: []byte("super")}}}),
+		cp.Writer().Delete("Counters", key),
+		cp.Writer().DeleteColumns("Counters", key, [][]byte{[]byte("one")}),
+	}
+	for i, w := range invalid {
+		if err = w.DryRun(); err == nil {
+			t.Error("Expected an error in dry run of invalid mutation ", i, ": ", w.Mutations())
+		}
+	}
+}
+
+func TestValidateMutation(t *testing.T) {
+	schema := NewSchema(&KeyspaceDefinition{ColumnFamilies: []*ColumnFamilyDefinition{
+		&ColumnFamilyDefinition{Name: "Counters", DefaultValidationClass: "CounterColumnType"},
+		&ColumnFamilyDefinition{Name: "SuperCounters", Super: true, DefaultValidationClass: "CounterColumnType"},
+	}})
+	key := []byte("key")
+	one := []*Column{&Column{Name: []byte("one")}}
+
+	if err := validateMutation(schema, &Mutation{Kind: CounterRemoveMutation, Cf: "Counters", Row: &Row{Key: key, Columns: one}}); err != nil {
+		t.Error("Unexpected error validating a counter removal: ", err)
+	}
+	invalid := []*Mutation{
+		&Mutation{Kind: DeleteMutation, Cf: "Counters", Row: &Row{Key: key}},
+		&Mutation{Kind: DeleteMutation, Cf: "Counters", Row: &Row{Key: key, Columns: one}},
+		&Mutation{Kind: CounterRemoveMutation, Cf: "SuperCounters", Row: &Row{Key: key, Columns: one}},
+	}
+	for _, m := range invalid {
+		if err := validateMutation(schema, m); err == nil {
+			t.Error("Expected an error validating ", m)
+		}
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	long, _ := Marshal(int64(1), LongType)
+	composite := append(packComposite(long, eocEquals), packComposite([]byte("a"), eocEquals)...)
+	compositeType := TypeClass{Desc: CompositeType, Components: []TypeClass{TypeClass{Desc: LongType}, TypeClass{Desc: AsciiType}}}
+
+	valid := []struct {
+		b  []byte
+		tc TypeClass
+	}{
+		{long, TypeClass{Desc: LongType}},
+		{[]byte{}, TypeClass{Desc: LongType}},
+		{[]byte{1, 2, 3, 4}, TypeClass{Desc: Int32Type}},
+		{[]byte("leña"), TypeClass{Desc: UTF8Type}},
+		{[]byte("ascii"), TypeClass{Desc: AsciiType}},
+		{[]byte{0xff}, TypeClass{Desc: BytesType}},
+		{composite, compositeType},
+	}
+	for _, v := range valid {
+		if err := validateValue(v.b, v.tc); err != nil {
+			t.Error("Unexpected error validating ", v.b, " as ", v.tc, ": ", err)
+		}
+	}
+
+	invalid := []struct {
+		b  []byte
+		tc TypeClass
+	}{
+		{[]byte{1}, TypeClass{Desc: LongType}},
+		{[]byte{1, 2, 3}, TypeClass{Desc: UUIDType}},
+		{[]byte{0xff, 0xfe}, TypeClass{Desc: UTF8Type}},
+		{[]byte("leña"), TypeClass{Desc: AsciiType}},
+		{composite[:5], compositeType},
+		{append(composite, packComposite([]byte("b"), eocEquals)...), compositeType},
+		{append(packComposite([]byte{1}, eocEquals), packComposite([]byte("a"), eocEquals)...), compositeType},
+	}
+	for _, v := range invalid {
+		if err := validateValue(v.b, v.tc); err == nil {
+			t.Error("Expected an error validating ", v.b, " as ", v.tc)
+		}
+	}
+}
+
+func TestMutationString(t *testing.T) {
+	m := &Mutation{
+		Kind:      DeleteMutation,
+		Cf:        "Cf",
+		Row:       &Row{Key: []byte{1}, Columns: []*Column{&Column{Name: []byte{2}}}},
+		Timestamp: 10,
+	}
+	if s := m.String(); s != "delete Cf[01] 02 at 10" {
+		t.Error("Unexpected mutation description: ", s)
+	}
+	m = &Mutation{
+		Kind: InsertMutation,
+		Cf:   "Cf",
+		Row:  &Row{Key: []byte{1}, Columns: []*Column{&Column{Name: []byte{2}, Value: []byte{3}}}},
+	}
+	if s := m.String(); s != "insert Cf[01] 02=03" {
+		t.Error("Unexpected mutation description: ", s)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	keys := [][]byte{[]byte("counters0"), []byte("counters1")}
+	w := cp.Writer()
+	for _, key := range keys {
+		w.RemoveCounter("Counters", key, nil)
+	}
+	if err = w.Run(); err != nil {
+		t.Fatal("Error removing counters: ", err)
+	}
+
+	w = cp.Writer()
+	for _, key := range keys {
+		w.DeltaCounters("Counters", buildCounterRow(string(key)))
+	}
+	w.DeltaCounters("Counters", buildCounterRow("counters1"))
+	if err = w.Run(); err != nil {
+		t.Fatal("Error running mutation: ", err)
+	}
+
+	v, err := cp.Reader().Cf("Counters").GetCounter(keys[1], []byte("fortytwo"))
+	if err != nil {
+		t.Fatal("Error reading counter: ", err)
+	}
+	if v != -84 {
+		t.Error("Expected counter value -84 but got ", v)
+	}
+
+	if _, err = cp.Reader().Cf("Counters").GetCounter(keys[0], []byte("missing")); err != ErrorNotFound {
+		t.Error("Expected ErrorNotFound for a missing counter but got ", err)
+	}
+	cp.Writer().Insert("AllTypes", buildAllTypesRow("counters0")).Run()
+	if _, err = cp.Reader().Cf("AllTypes").GetCounter([]byte("counters0"), []byte("colLongType")); err != ErrorNotCounter {
+		t.Error("Expected ErrorNotCounter for a regular column but got ", err)
+	}
+	if _, err = cp.Reader().Cf("AllTypes").MultiGetCounters(keys); err != ErrorNotCounter {
+		t.Error("Expected ErrorNotCounter for regular columns but got ", err)
+	}
+	cp.Writer().Delete("AllTypes", []byte("counters0")).Run()
+
+	// a regular column written at timestamp 0 with an 8 byte value is still not a counter, the key
+	// is unique and the column expires since it cannot be deleted without shadowing later runs
+	zero := []byte(fmt.Sprint("counterszero", time.Now().UnixNano()))
+	long, _ := Marshal(int64(42), LongType)
+	err = cp.Writer().Timestamps(NewFixedClock(0)).InsertTtl("AllTypes", &Row{Key: zero, Columns: []*Column{&Column{Name: []byte("colLongType"), Value: long}}}, 60).Run()
+	if err != nil {
+		t.Fatal("Error writing a column at timestamp 0: ", err)
+	}
+	if _, err = cp.Reader().Cf("AllTypes").MultiGetCounters([][]byte{zero}); err != ErrorNotCounter {
+		t.Error("Expected ErrorNotCounter for a regular column at timestamp 0 but got ", err)
+	}
+
+	rows, err := cp.Reader().Cf("Counters").KeyOrder(true).MultiGetCounters(keys)
+	if err != nil {
+		t.Fatal("Error reading counters: ", err)
+	}
+	if len(rows) != 2 {
+		t.Fatal("Expected 2 counter rows but got ", len(rows))
+	}
+	for i, row := range rows {
+		if !reflect.DeepEqual(row.Key, keys[i]) || len(row.Counters) != 3 {
+			t.Fatal("Unexpected counter row: ", row)
+		}
+		if string(row.Counters[2].Name) != "wtf" || row.Counters[2].Value != int64(i+1)*1e15 {
+			t.Error("Unexpected counter in row ", i, ": ", row.Counters[2])
+		}
+	}
+
+	if err = cp.Writer().RemoveCounter("Counters", keys[1], []byte("one")).Run(); err != nil {
+		t.Fatal("Error removing counter: ", err)
+	}
+	if _, err = cp.Reader().Cf("Counters").GetCounter(keys[1], []byte("one")); err != ErrorNotFound {
+		t.Error("Expected ErrorNotFound for a removed counter but got ", err)
+	}
+	count, err := cp.Reader().Cf("Counters").Count(keys[1])
+	if err != nil {
+		t.Fatal("Error running query: ", err)
+	}
+	if count != 2 {
+		t.Error("Expected 2 counters after removing one but got ", count)
+	}
+
+	w = cp.Writer()
+	for _, key := range keys {
+		w.RemoveCounter("Counters", key, nil)
+	}
+	if err = w.DryRun(); err != nil {
+		t.Error("Unexpected error in dry run: ", err)
+	}
+	if err = w.Run(); err != nil {
+		t.Error("Error removing counters: ", err)
+	}
+	if err = cp.Writer().RemoveCounter("AllTypes", keys[0], nil).DryRun(); err == nil {
+		t.Error("Expected an error in dry run of counter removal in a regular column family")
+	}
+}
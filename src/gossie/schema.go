@@ -1,66 +1,244 @@
 package gossie
 
 import (
+	"encoding/json"
 	"github.com/carloscm/gossie/src/cassandra"
+	"reflect"
+	"sort"
 )
 
 /*
 to do:
-    generate CQL schema from tagged Go structs
-    validate tagged Go structs against schemas
-    handle ReversedType
-    handle type options
 	handle composited column names in the schema (is this in use/allowed?)
 */
 
+// Schema is the description of the keyspace of a connection pool. Definition keeps all the
+// attributes of the keyspace as reported by Cassandra.
 type Schema struct {
+	Definition     *KeyspaceDefinition
 	ColumnFamilies map[string]*ColumnFamily
 }
 
+// ColumnFamily is the description of a column family, with its comparators and validators already
+// parsed. Definition keeps all the attributes of the column family as reported by Cassandra, and
+// Columns the full metadata of its named columns, including their indexes.
 type ColumnFamily struct {
+	Super             bool
 	DefaultComparator TypeClass
+	SubComparator     TypeClass
 	DefaultValidator  TypeClass
 	KeyValidator      TypeClass
 	NamedColumns      map[string]TypeClass
+	Columns           map[string]*ColumnDefinition
+	Definition        *ColumnFamilyDefinition
+}
+
+// Indexed returns true if the named column has a secondary index, so it can be used in a
+// Reader.Where expression
+func (cf *ColumnFamily) Indexed(column string) bool {
+	c, found := cf.Columns[column]
+	return found && c.IndexType != NoIndex
+}
+
+// IndexedColumns returns the sorted names of the columns with a secondary index
+func (cf *ColumnFamily) IndexedColumns() []string {
+	names := make([]string, 0)
+	for name, c := range cf.Columns {
+		if c.IndexType != NoIndex {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 func newSchema(ksDef *cassandra.KsDef) *Schema {
-	cfDefs := ksDef.CfDefs
-	schema := &Schema{ColumnFamilies: make(map[string]*ColumnFamily)}
+	return NewSchema(keyspaceDefinitionFromCassandra(ksDef))
+}
 
-	for cfDefT := range cfDefs.Iter() {
+// NewSchema builds a Schema from a keyspace definition, parsing its type classes
+func NewSchema(ks *KeyspaceDefinition) *Schema {
+	schema := &Schema{Definition: ks, ColumnFamilies: make(map[string]*ColumnFamily)}
 
-		// FIXME: this is weird, but happens a lot. thrift4go problem?
-		if cfDefT == nil {
-			continue
+	for _, d := range ks.ColumnFamilies {
+
+		cf := &ColumnFamily{Definition: d}
+
+		cf.Super = d.Super
+		if d.Super {
+			cf.SubComparator = parseTypeClass(d.SubComparator)
+		}
+
+		cf.DefaultComparator = parseTypeClass(d.Comparator)
+		cf.DefaultValidator = parseTypeClass(d.DefaultValidationClass)
+		cf.KeyValidator = parseTypeClass(d.KeyValidationClass)
+
+		cf.NamedColumns = make(map[string]TypeClass)
+		cf.Columns = make(map[string]*ColumnDefinition)
+
+		for _, c := range d.Columns {
+			name := string(c.Name)
+			cf.NamedColumns[name] = parseTypeClass(c.ValidationClass)
+			cf.Columns[name] = c
 		}
 
-		cfDef, _ := cfDefT.(*cassandra.CfDef)
+		schema.ColumnFamilies[d.Name] = cf
+	}
+
+	return schema
+}
+
+// MarshalJSON writes the keyspace definition of the schema as JSON. Everything else in a Schema is
+// derived from it, so the JSON form is lossless.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Definition)
+}
+
+// UnmarshalJSON reads a keyspace definition in the form written by MarshalJSON and rebuilds the
+// schema from it
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	d := &KeyspaceDefinition{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return err
+	}
+	*s = *NewSchema(d)
+	return nil
+}
+
+// SchemaChange describes the differences between two versions of a keyspace schema.
+// AttributesChanged is set if the keyspace attributes, like its replication options, changed.
+type SchemaChange struct {
+	Old                   *Schema
+	New                   *Schema
+	AttributesChanged     bool
+	AddedColumnFamilies   []string
+	RemovedColumnFamilies []string
+	ChangedColumnFamilies []*ColumnFamilyChange
+}
+
+// ColumnFamilyChange describes the differences between two versions of a column family present in
+// both. AttributesChanged is set if anything besides the named columns changed. A named column is
+// changed if its type or any of its metadata, like its index, changed.
+type ColumnFamilyChange struct {
+	Name              string
+	Old               *ColumnFamily
+	New               *ColumnFamily
+	AttributesChanged bool
+	AddedColumns      []string
+	RemovedColumns    []string
+	ChangedColumns    []string
+}
+
+// Empty returns true if the schemas are the same
+func (c *SchemaChange) Empty() bool {
+	return !c.AttributesChanged && len(c.AddedColumnFamilies) == 0 && len(c.RemovedColumnFamilies) == 0 && len(c.ChangedColumnFamilies) == 0
+}
 
-		if cfDef.ColumnType != "Standard" {
+// DiffSchemas compares two schemas and returns their differences. Column family and column names
+// are returned sorted.
+func DiffSchemas(old, updated *Schema) *SchemaChange {
+	change := &SchemaChange{
+		Old:                   old,
+		New:                   updated,
+		AddedColumnFamilies:   make([]string, 0),
+		RemovedColumnFamilies: make([]string, 0),
+		ChangedColumnFamilies: make([]*ColumnFamilyChange, 0),
+	}
+
+	// compare everything but the column families
+	if old.Definition != nil && updated.Definition != nil {
+		oldAttributes := *old.Definition
+		newAttributes := *updated.Definition
+		oldAttributes.ColumnFamilies = nil
+		newAttributes.ColumnFamilies = nil
+		change.AttributesChanged = !reflect.DeepEqual(oldAttributes, newAttributes)
+	} else {
+		change.AttributesChanged = old.Definition != updated.Definition
+	}
+
+	for _, name := range sortedKeys(updated.ColumnFamilies) {
+		oldCf, found := old.ColumnFamilies[name]
+		if !found {
+			change.AddedColumnFamilies = append(change.AddedColumnFamilies, name)
 			continue
 		}
+		if cfChange := diffColumnFamilies(name, oldCf, updated.ColumnFamilies[name]); cfChange != nil {
+			change.ChangedColumnFamilies = append(change.ChangedColumnFamilies, cfChange)
+		}
+	}
+	for _, name := range sortedKeys(old.ColumnFamilies) {
+		if _, found := updated.ColumnFamilies[name]; !found {
+			change.RemovedColumnFamilies = append(change.RemovedColumnFamilies, name)
+		}
+	}
 
-		cf := &ColumnFamily{}
+	return change
+}
 
-		cf.DefaultComparator = parseTypeClass(cfDef.ComparatorType)
-		cf.DefaultValidator = parseTypeClass(cfDef.DefaultValidationClass)
-		cf.KeyValidator = parseTypeClass(cfDef.KeyValidationClass)
+func diffColumnFamilies(name string, old, updated *ColumnFamily) *ColumnFamilyChange {
+	change := &ColumnFamilyChange{
+		Name:           name,
+		Old:            old,
+		New:            updated,
+		AddedColumns:   make([]string, 0),
+		RemovedColumns: make([]string, 0),
+		ChangedColumns: make([]string, 0),
+	}
 
-		cf.NamedColumns = make(map[string]TypeClass)
+	// compare everything but the named columns
+	oldAttributes := *old
+	newAttributes := *updated
+	oldAttributes.NamedColumns = nil
+	newAttributes.NamedColumns = nil
+	oldAttributes.Columns = nil
+	newAttributes.Columns = nil
+	if old.Definition != nil {
+		d := *old.Definition
+		d.Columns = nil
+		oldAttributes.Definition = &d
+	}
+	if updated.Definition != nil {
+		d := *updated.Definition
+		d.Columns = nil
+		newAttributes.Definition = &d
+	}
+	change.AttributesChanged = !reflect.DeepEqual(oldAttributes, newAttributes)
 
-		for colDefT := range cfDef.ColumnMetadata.Iter() {
-			// FIXME: this is weird, but happens a lot. thrift4go problem?
-			if colDefT == nil {
-				continue
-			}
-			colDef, _ := colDefT.(*cassandra.ColumnDef)
-			name := string(colDef.Name[0:(len(colDef.Name))])
-			cf.NamedColumns[name] = parseTypeClass(colDef.ValidationClass)
+	for _, column := range sortedColumns(updated.NamedColumns) {
+		oldColumn, found := old.NamedColumns[column]
+		if !found {
+			change.AddedColumns = append(change.AddedColumns, column)
+		} else if !reflect.DeepEqual(oldColumn, updated.NamedColumns[column]) ||
+			!reflect.DeepEqual(old.Columns[column], updated.Columns[column]) {
+			change.ChangedColumns = append(change.ChangedColumns, column)
 		}
+	}
+	for _, column := range sortedColumns(old.NamedColumns) {
+		if _, found := updated.NamedColumns[column]; !found {
+			change.RemovedColumns = append(change.RemovedColumns, column)
+		}
+	}
 
-		schema.ColumnFamilies[cfDef.Name] = cf
+	if !change.AttributesChanged && len(change.AddedColumns) == 0 && len(change.RemovedColumns) == 0 && len(change.ChangedColumns) == 0 {
+		return nil
 	}
+	return change
+}
 
-	return schema
+func sortedKeys(cfs map[string]*ColumnFamily) []string {
+	names := make([]string, 0, len(cfs))
+	for name := range cfs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedColumns(columns map[string]TypeClass) []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
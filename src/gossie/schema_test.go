@@ -1,7 +1,10 @@
 package gossie
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSchema(t *testing.T) {
@@ -16,8 +19,14 @@ func TestSchema(t *testing.T) {
 	schema := newSchema(ksDef)
 	defer c.close()
 
-	if len(schema.ColumnFamilies) != 8 {
-		t.Error("Test schema must have 8 CFs")
+	if len(schema.ColumnFamilies) != 9 {
+		t.Error("Test schema must have 9 CFs")
+	}
+
+	ks := schema.Definition
+	if ks.Name != keyspace || !strings.HasSuffix(ks.StrategyClass, "SimpleStrategy") ||
+		ks.StrategyOptions["replication_factor"] != "1" || !ks.DurableWrites || len(ks.ColumnFamilies) != 9 {
+		t.Error("Unexpected keyspace definition: ", ks)
 	}
 
 	if schema.ColumnFamilies["AllTypes"] == nil {
@@ -57,6 +66,28 @@ func TestSchema(t *testing.T) {
 			if cf.NamedColumns[name].Desc != desc {
 				t.Error("Test CF AllTypes column ", name, " is not the expected type")
 			}
+			if cf.Columns[name] == nil || cf.Columns[name].ValidationClass == "" {
+				t.Error("Test CF AllTypes column ", name, " has no definition")
+			}
+		}
+
+		if !reflect.DeepEqual(cf.IndexedColumns(), []string{"colAsciiType", "colLongType"}) {
+			t.Error("Test CF AllTypes has unexpected indexed columns: ", cf.IndexedColumns())
+		}
+		if !cf.Indexed("colAsciiType") || cf.Columns["colAsciiType"].IndexType != KeysIndex {
+			t.Error("Test CF AllTypes column colAsciiType is not indexed")
+		}
+		if cf.Indexed("colUTF8Type") || cf.Indexed("notAColumn") {
+			t.Error("Test CF AllTypes has unexpected indexes")
+		}
+
+		d := cf.Definition
+		if d.Name != "AllTypes" || d.Id == 0 || d.Super || !strings.HasSuffix(d.Comparator, "AsciiType") ||
+			!strings.HasSuffix(d.DefaultValidationClass, "UTF8Type") || len(d.Columns) != len(check) {
+			t.Error("Unexpected Test CF AllTypes definition: ", d)
+		}
+		if d.GcGraceSeconds <= 0 || d.MinCompactionThreshold <= 0 || d.MaxCompactionThreshold < d.MinCompactionThreshold {
+			t.Error("Test CF AllTypes definition is missing the server defaults: ", d)
 		}
 	}
 
@@ -112,6 +143,26 @@ func TestSchema(t *testing.T) {
 		}
 	}
 
+	if schema.ColumnFamilies["Super"] == nil {
+		t.Error("Test CF Super is nil")
+	} else {
+		cf := schema.ColumnFamilies["Super"]
+
+		if !cf.Super || !cf.Definition.Super {
+			t.Error("Test CF Super is not marked as a super column family")
+		}
+		if cf.DefaultComparator.Desc != AsciiType {
+			t.Error("Test CF Super DefaultComparator is not AsciiType")
+		}
+		if cf.SubComparator.Desc != UTF8Type {
+			t.Error("Test CF Super SubComparator is not UTF8Type")
+		}
+	}
+
+	if schema.ColumnFamilies["AllTypes"] != nil && schema.ColumnFamilies["AllTypes"].Super {
+		t.Error("Test CF AllTypes is marked as a super column family")
+	}
+
 	if schema.ColumnFamilies["Timeseries"] == nil {
 		t.Error("Test CF Timeseries is nil")
 	} else {
@@ -150,3 +201,187 @@ func TestSchema(t *testing.T) {
 	}
 
 }
+
+func TestDiffSchemas(t *testing.T) {
+	old := &Schema{ColumnFamilies: map[string]*ColumnFamily{
+		"Kept": &ColumnFamily{
+			DefaultComparator: TypeClass{Desc: AsciiType},
+			NamedColumns:      map[string]TypeClass{"a": TypeClass{Desc: LongType}},
+		},
+		"Changed": &ColumnFamily{
+			DefaultComparator: TypeClass{Desc: AsciiType},
+			NamedColumns: map[string]TypeClass{
+				"kept":    TypeClass{Desc: LongType},
+				"changed": TypeClass{Desc: LongType},
+				"removed": TypeClass{Desc: LongType},
+			},
+		},
+		"Removed": &ColumnFamily{NamedColumns: map[string]TypeClass{}},
+	}}
+	updated := &Schema{ColumnFamilies: map[string]*ColumnFamily{
+		"Kept": &ColumnFamily{
+			DefaultComparator: TypeClass{Desc: AsciiType},
+			NamedColumns:      map[string]TypeClass{"a": TypeClass{Desc: LongType}},
+		},
+		"Changed": &ColumnFamily{
+			DefaultComparator: TypeClass{Desc: UTF8Type},
+			NamedColumns: map[string]TypeClass{
+				"kept":    TypeClass{Desc: LongType},
+				"changed": TypeClass{Desc: UTF8Type},
+				"added":   TypeClass{Desc: LongType},
+			},
+		},
+		"Added": &ColumnFamily{NamedColumns: map[string]TypeClass{}},
+	}}
+
+	if !DiffSchemas(old, old).Empty() {
+		t.Error("Expected no changes comparing a schema with itself")
+	}
+
+	change := DiffSchemas(old, updated)
+	if change.Empty() {
+		t.Fatal("Expected changes")
+	}
+	if !reflect.DeepEqual(change.AddedColumnFamilies, []string{"Added"}) {
+		t.Error("Unexpected added column families: ", change.AddedColumnFamilies)
+	}
+	if !reflect.DeepEqual(change.RemovedColumnFamilies, []string{"Removed"}) {
+		t.Error("Unexpected removed column families: ", change.RemovedColumnFamilies)
+	}
+	if len(change.ChangedColumnFamilies) != 1 {
+		t.Fatal("Unexpected changed column families: ", change.ChangedColumnFamilies)
+	}
+	cf := change.ChangedColumnFamilies[0]
+	if cf.Name != "Changed" || !cf.AttributesChanged || cf.Old != old.ColumnFamilies["Changed"] || cf.New != updated.ColumnFamilies["Changed"] {
+		t.Error("Unexpected column family change: ", cf)
+	}
+	if !reflect.DeepEqual(cf.AddedColumns, []string{"added"}) ||
+		!reflect.DeepEqual(cf.RemovedColumns, []string{"removed"}) ||
+		!reflect.DeepEqual(cf.ChangedColumns, []string{"changed"}) {
+		t.Error("Unexpected column changes: ", cf.AddedColumns, cf.RemovedColumns, cf.ChangedColumns)
+	}
+	if change.AttributesChanged {
+		t.Error("Unexpected keyspace attributes change")
+	}
+
+	// index and keyspace changes
+	indexed := &Schema{
+		Definition: &KeyspaceDefinition{Name: "ks", DurableWrites: true},
+		ColumnFamilies: map[string]*ColumnFamily{
+			"Cf": &ColumnFamily{
+				NamedColumns: map[string]TypeClass{"a": TypeClass{Desc: LongType}},
+				Columns:      map[string]*ColumnDefinition{"a": &ColumnDefinition{Name: []byte("a"), ValidationClass: "LongType"}},
+				Definition:   &ColumnFamilyDefinition{Name: "Cf", Columns: []*ColumnDefinition{&ColumnDefinition{Name: []byte("a"), ValidationClass: "LongType"}}},
+			},
+		},
+	}
+	reindexed := &Schema{
+		Definition: &KeyspaceDefinition{Name: "ks", DurableWrites: false},
+		ColumnFamilies: map[string]*ColumnFamily{
+			"Cf": &ColumnFamily{
+				NamedColumns: map[string]TypeClass{"a": TypeClass{Desc: LongType}},
+				Columns:      map[string]*ColumnDefinition{"a": &ColumnDefinition{Name: []byte("a"), ValidationClass: "LongType", IndexType: KeysIndex}},
+				Definition:   &ColumnFamilyDefinition{Name: "Cf", Columns: []*ColumnDefinition{&ColumnDefinition{Name: []byte("a"), ValidationClass: "LongType", IndexType: KeysIndex}}},
+			},
+		},
+	}
+	change = DiffSchemas(indexed, reindexed)
+	if !change.AttributesChanged || change.Empty() {
+		t.Error("Expected a keyspace attributes change")
+	}
+	if len(change.ChangedColumnFamilies) != 1 {
+		t.Fatal("Unexpected changed column families: ", change.ChangedColumnFamilies)
+	}
+	cf = change.ChangedColumnFamilies[0]
+	if cf.AttributesChanged || !reflect.DeepEqual(cf.ChangedColumns, []string{"a"}) {
+		t.Error("Expected only an index change in column a but got ", cf.AttributesChanged, cf.ChangedColumns)
+	}
+	if !reindexed.ColumnFamilies["Cf"].Indexed("a") || indexed.ColumnFamilies["Cf"].Indexed("a") {
+		t.Error("Unexpected Indexed result")
+	}
+}
+
+func TestSchemaRefresh(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: 10000, SchemaRefresh: 1})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+	other, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: 10000})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer other.Close()
+
+	changes := make(chan *SchemaChange, 10)
+	cp.OnSchemaChange(func(change *SchemaChange) {
+		changes <- change
+	})
+	// listeners can use the pool, including refreshing the schema again
+	nested := make(chan error, 10)
+	cp.OnSchemaChange(func(change *SchemaChange) {
+		_, err := cp.RefreshSchema()
+		nested <- err
+	})
+
+	change, err := cp.RefreshSchema()
+	if err != nil {
+		t.Fatal("Error refreshing schema: ", err)
+	}
+	if !change.Empty() {
+		t.Error("Expected no changes refreshing an unchanged schema: ", change)
+	}
+
+	before := cp.Schema()
+	if err = other.Admin().AddColumnFamily(&ColumnFamilyDefinition{Name: "Refresh"}); err != nil {
+		t.Fatal("Error adding column family: ", err)
+	}
+
+	// the change is made through another pool, so it is found by the periodic check
+	select {
+	case change = <-changes:
+		if !reflect.DeepEqual(change.AddedColumnFamilies, []string{"Refresh"}) || change.Old != before {
+			t.Error("Unexpected schema change: ", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Schema change was not detected")
+	}
+	select {
+	case err = <-nested:
+		if err != nil {
+			t.Error("Error refreshing the schema from a listener: ", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Refreshing the schema from a listener did not return")
+	}
+	if _, found := cp.Schema().ColumnFamilies["Refresh"]; !found {
+		t.Error("Refreshed schema does not contain the new column family")
+	}
+	if _, found := before.ColumnFamilies["Refresh"]; found {
+		t.Error("The previous schema was modified by the refresh")
+	}
+
+	// changes made through the pool refresh its schema at once
+	if err = cp.Admin().DropColumnFamily("Refresh"); err != nil {
+		t.Fatal("Error dropping column family: ", err)
+	}
+	if _, found := cp.Schema().ColumnFamilies["Refresh"]; found {
+		t.Error("Dropped column family is still present in the schema")
+	}
+	select {
+	case change = <-changes:
+		if !reflect.DeepEqual(change.RemovedColumnFamilies, []string{"Refresh"}) {
+			t.Error("Unexpected schema change: ", change)
+		}
+	default:
+		t.Error("Schema change from Admin was not notified")
+	}
+	select {
+	case err = <-nested:
+		if err != nil {
+			t.Error("Error refreshing the schema from a listener: ", err)
+		}
+	default:
+		t.Error("Listener refreshing the schema was not called for the Admin change")
+	}
+}
@@ -20,6 +20,7 @@ type field struct {
 	index         int
 	cassandraName string
 	cassandraType TypeDesc
+	indexed       bool // secondary index requested with the 'index' tag
 }
 
 var recognizedGlobalTags []string = []string{"mapping", "cf", "key", "cols", "value"}
@@ -49,7 +50,9 @@ func newField(index int, sf reflect.StructField) (*field, error) {
 		cassandraName = tagName
 	}
 
-	return &field{name, index, cassandraName, cassandraType}, nil
+	indexed := sf.Tag.Get("index") == "true"
+
+	return &field{name, index, cassandraName, cassandraType, indexed}, nil
 }
 
 func (f *field) marshalName() ([]byte, error) {
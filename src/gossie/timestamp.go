@@ -0,0 +1,82 @@
+package gossie
+
+import (
+	"sync"
+	"time"
+)
+
+// TimestampProvider generates the timestamps used for writes and deletions. Timestamps are
+// microseconds since the Unix epoch, following the convention of other Cassandra clients.
+type TimestampProvider interface {
+
+	// Timestamp returns the timestamp for a new write
+	Timestamp() int64
+}
+
+var (
+	// WallClock returns timestamps based on the local wall clock. This is the default provider.
+	WallClock TimestampProvider = &wallClock{}
+
+	// MonotonicClock returns timestamps based on the local wall clock, but guarantees they are
+	// strictly increasing for all the writes performed by this process, even when the wall clock
+	// goes backwards or when several writes happen in the same microsecond.
+	MonotonicClock TimestampProvider = &monotonicClock{}
+)
+
+func now() int64 {
+	return time.Now().UnixNano() / 1000
+}
+
+type wallClock struct{}
+
+func (c *wallClock) Timestamp() int64 {
+	return now()
+}
+
+type monotonicClock struct {
+	mutex sync.Mutex
+	last  int64
+}
+
+func (c *monotonicClock) Timestamp() int64 {
+	t := now()
+	c.mutex.Lock()
+	if t <= c.last {
+		t = c.last + 1
+	}
+	c.last = t
+	c.mutex.Unlock()
+	return t
+}
+
+// FixedClock is a TimestampProvider that always returns the same timestamp until it is changed.
+// It is useful for tests and for writes that must share an externally decided timestamp.
+type FixedClock struct {
+	mutex     sync.Mutex
+	timestamp int64
+}
+
+// NewFixedClock returns a FixedClock set to the passed timestamp
+func NewFixedClock(timestamp int64) *FixedClock {
+	return &FixedClock{timestamp: timestamp}
+}
+
+func (c *FixedClock) Timestamp() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.timestamp
+}
+
+// Set changes the returned timestamp to the passed one
+func (c *FixedClock) Set(timestamp int64) {
+	c.mutex.Lock()
+	c.timestamp = timestamp
+	c.mutex.Unlock()
+}
+
+// Advance adds delta microseconds to the returned timestamp
+func (c *FixedClock) Advance(delta int64) {
+	c.mutex.Lock()
+	c.timestamp += delta
+	c.mutex.Unlock()
+}
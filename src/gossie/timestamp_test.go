@@ -0,0 +1,41 @@
+package gossie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWallClock(t *testing.T) {
+	before := time.Now().UnixNano() / 1000
+	ts := WallClock.Timestamp()
+	after := time.Now().UnixNano() / 1000
+	if ts < before || ts > after {
+		t.Error("Wall clock timestamp ", ts, " is not between ", before, " and ", after)
+	}
+}
+
+func TestMonotonicClock(t *testing.T) {
+	last := MonotonicClock.Timestamp()
+	for i := 0; i < 10000; i++ {
+		ts := MonotonicClock.Timestamp()
+		if ts <= last {
+			t.Fatal("Monotonic clock timestamp ", ts, " is not greater than the previous one ", last)
+		}
+		last = ts
+	}
+}
+
+func TestFixedClock(t *testing.T) {
+	c := NewFixedClock(1000)
+	if c.Timestamp() != 1000 || c.Timestamp() != 1000 {
+		t.Error("Fixed clock did not return the fixed timestamp")
+	}
+	c.Advance(10)
+	if c.Timestamp() != 1010 {
+		t.Error("Fixed clock was not advanced")
+	}
+	c.Set(42)
+	if c.Timestamp() != 42 {
+		t.Error("Fixed clock was not set")
+	}
+}
@@ -0,0 +1,467 @@
+package gossie
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+	to do:
+	typed access to super column families
+*/
+
+// TypedColumn is a column with its name and value decoded into Go values
+type TypedColumn struct {
+	Name      interface{}
+	Value     interface{}
+	Ttl       int32
+	Timestamp int64
+}
+
+// TypedRow is a row with its key and columns decoded into Go values
+type TypedRow struct {
+	Key     interface{}
+	Columns []*TypedColumn
+}
+
+// TypedReader reads rows using the keyspace schema to marshal keys and column names from Go
+// values, and to decode the returned rows back into Go values. Keys use the key validator of the
+// column family, column names its comparator, and values the validator of the named column or the
+// default validator. Names for composite comparators are passed as []interface{} with the values
+// of the components in order, and are decoded the same way. Decoded values are []byte, string,
+// int64, int32, UUID, bool, float32, float64 or time.Time depending on their type.
+type TypedReader interface {
+
+	// ConsistencyLevel sets the consistency level for this particular call.
+	// It is optional, if left uncalled it will default to your connection pool options value.
+	ConsistencyLevel(int) TypedReader
+
+	// Cf sets the column family name for the reader. The column family must be in the schema.
+	// This method must be always called.
+	Cf(string) TypedReader
+
+	// Slice optionally sets a range of column names to return, with nil meaning an open bound.
+	// Composite bounds can be prefixes with fewer values than components, and both bounds include
+	// all the columns starting with them.
+	Slice(start, end interface{}, count int, reversed bool) TypedReader
+
+	// Columns optionally filters the returned columns to only the passed set of column names
+	Columns(names ...interface{}) TypedReader
+
+	// Raw returns the underlying Reader, with the column family and the options set so far
+	Raw() Reader
+
+	// Get looks up a row with the given key and returns it, or nil if it does not exist.
+	Get(key interface{}) (*TypedRow, error)
+
+	// MultiGet looks up multiple rows given the keys
+	MultiGet(keys []interface{}) ([]*TypedRow, error)
+}
+
+// TypedWriter adds write operations marshaling keys, column names and values from Go values with
+// the types of the keyspace schema, see TypedReader. Marshaling errors are returned by Run, and the
+// operations are not sent at all in that case.
+type TypedWriter interface {
+
+	// ConsistencyLevel sets the consistency level for this particular call.
+	// It is optional, if left uncalled it will default to your connection
+	// pool options value.
+	ConsistencyLevel(int) TypedWriter
+
+	// Insert adds a new column insertion to the mutation
+	Insert(cf string, key, name, value interface{}) TypedWriter
+
+	// InsertTtl adds a new column insertion to the mutation with the passed Ttl
+	InsertTtl(cf string, key, name, value interface{}, ttl int) TypedWriter
+
+	// Increment adds a delta operation over a counter column
+	Increment(cf string, key, name interface{}, delta int64) TypedWriter
+
+	// Delete deletes a single row specified by key
+	Delete(cf string, key interface{}) TypedWriter
+
+	// DeleteColumns deletes the passed columns from the row specified by key
+	DeleteColumns(cf string, key interface{}, names ...interface{}) TypedWriter
+
+	// Raw returns the underlying Writer, which can be used to add raw operations to the same
+	// mutation
+	Raw() Writer
+
+	// Run this mutation, see Writer.Run
+	Run() error
+}
+
+// typedColumnFamily looks up a column family supported by the typed layer in the current schema
+func typedColumnFamily(cp *connectionPool, name string) (*ColumnFamily, error) {
+	schema := cp.Schema()
+	if schema == nil {
+		return nil, ErrorCfNotFound
+	}
+	cf, found := schema.ColumnFamilies[name]
+	if !found {
+		return nil, ErrorCfNotFound
+	}
+	if cf.Super {
+		return nil, errors.New(fmt.Sprint("Typed access is not supported for super column family ", name))
+	}
+	return cf, nil
+}
+
+// valueClass returns the type of the values of the named column
+func (cf *ColumnFamily) valueClass(name []byte) TypeClass {
+	if tc, found := cf.NamedColumns[string(name)]; found {
+		return tc
+	}
+	return cf.DefaultValidator
+}
+
+// encodeValue marshals a Go value with the passed type. Composite values are passed as
+// []interface{}, or as a single value for the first component.
+func encodeValue(value interface{}, tc TypeClass) ([]byte, error) {
+	return encodeBound(value, tc, eocEquals)
+}
+
+// encodeBound works like encodeValue, using eoc as the end of component byte of the last component
+// of composites, to build slice bounds
+func encodeBound(value interface{}, tc TypeClass, eoc byte) ([]byte, error) {
+	if tc.Desc != CompositeType {
+		return Marshal(value, tc.Desc)
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	if len(values) > len(tc.Components) {
+		return nil, errors.New(fmt.Sprint("Passed ", len(values), " values for a composite of ", len(tc.Components), " components"))
+	}
+	b := make([]byte, 0)
+	for i, v := range values {
+		cb, err := Marshal(v, tc.Components[i].Desc)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error marshaling composite component ", i, ": ", err))
+		}
+		if i == len(values)-1 {
+			b = append(b, packComposite(cb, eoc)...)
+		} else {
+			b = append(b, packComposite(cb, eocEquals)...)
+		}
+	}
+	return b, nil
+}
+
+// decodeValue unmarshals b into the natural Go value for the passed type
+func decodeValue(b []byte, tc TypeClass) (interface{}, error) {
+	var err error
+	switch tc.Desc {
+	case CompositeType:
+		components, err := safeUnpackComposite(b)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(components))
+		for i, c := range components {
+			ct := TypeClass{Desc: BytesType}
+			if i < len(tc.Components) {
+				ct = tc.Components[i]
+			}
+			if values[i], err = decodeValue(c, ct); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case AsciiType, UTF8Type:
+		var v string
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case LongType, CounterColumnType:
+		var v int64
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case Int32Type:
+		var v int32
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case UUIDType, TimeUUIDType, LexicalUUIDType:
+		var v UUID
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case BooleanType:
+		var v bool
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case FloatType:
+		var v float32
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case DoubleType:
+		var v float64
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	case DateType:
+		var v time.Time
+		err = Unmarshal(b, tc.Desc, &v)
+		return v, err
+	}
+	return b, nil
+}
+
+// decodeRow decodes a raw row read from the passed column family
+func decodeRow(cf *ColumnFamily, row *Row) (*TypedRow, error) {
+	key, err := decodeValue(row.Key, cf.KeyValidator)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("Error decoding row key: ", err))
+	}
+	tr := &TypedRow{Key: key, Columns: make([]*TypedColumn, 0, len(row.Columns))}
+	for _, c := range row.Columns {
+		name, err := decodeValue(c.Name, cf.DefaultComparator)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error decoding column name ", c.Name, ": ", err))
+		}
+		value, err := decodeValue(c.Value, cf.valueClass(c.Name))
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("Error decoding value of column ", c.Name, ": ", err))
+		}
+		tr.Columns = append(tr.Columns, &TypedColumn{Name: name, Value: value, Ttl: c.Ttl, Timestamp: c.Timestamp})
+	}
+	return tr, nil
+}
+
+type typedReader struct {
+	pool   *connectionPool
+	reader Reader
+	cf     *ColumnFamily
+	err    error
+}
+
+func newTypedReader(cp *connectionPool) *typedReader {
+	return &typedReader{pool: cp, reader: cp.Reader()}
+}
+
+// fail keeps the first error found building the reader
+func (r *typedReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *typedReader) ConsistencyLevel(l int) TypedReader {
+	r.reader.ConsistencyLevel(l)
+	return r
+}
+
+func (r *typedReader) Cf(name string) TypedReader {
+	cf, err := typedColumnFamily(r.pool, name)
+	if err != nil {
+		r.fail(err)
+	}
+	r.cf = cf
+	r.reader.Cf(name)
+	return r
+}
+
+func (r *typedReader) Slice(start, end interface{}, count int, reversed bool) TypedReader {
+	if r.cf == nil {
+		r.fail(errors.New("No column family specified"))
+		return r
+	}
+	// the bound that comes last in comparator order must sort after all the columns of its prefix
+	startEoc, endEoc := eocEquals, eocGreater
+	if reversed {
+		startEoc, endEoc = eocGreater, eocEquals
+	}
+	slice := &Slice{Count: count, Reversed: reversed}
+	var err error
+	if start != nil {
+		if slice.Start, err = encodeBound(start, r.cf.DefaultComparator, startEoc); err != nil {
+			r.fail(errors.New(fmt.Sprint("Error marshaling slice start: ", err)))
+		}
+	}
+	if end != nil {
+		if slice.End, err = encodeBound(end, r.cf.DefaultComparator, endEoc); err != nil {
+			r.fail(errors.New(fmt.Sprint("Error marshaling slice end: ", err)))
+		}
+	}
+	r.reader.Slice(slice)
+	return r
+}
+
+func (r *typedReader) Columns(names ...interface{}) TypedReader {
+	if r.cf == nil {
+		r.fail(errors.New("No column family specified"))
+		return r
+	}
+	columns := make([][]byte, 0, len(names))
+	for _, name := range names {
+		b, err := encodeValue(name, r.cf.DefaultComparator)
+		if err != nil {
+			r.fail(errors.New(fmt.Sprint("Error marshaling column name ", name, ": ", err)))
+		}
+		columns = append(columns, b)
+	}
+	r.reader.Columns(columns)
+	return r
+}
+
+func (r *typedReader) Raw() Reader {
+	return r.reader
+}
+
+func (r *typedReader) marshalKey(key interface{}) ([]byte, error) {
+	b, err := encodeValue(key, r.cf.KeyValidator)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("Error marshaling row key ", key, ": ", err))
+	}
+	return b, nil
+}
+
+func (r *typedReader) Get(key interface{}) (*TypedRow, error) {
+	if r.err == nil && r.cf == nil {
+		r.fail(errors.New("No column family specified"))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	keyB, err := r.marshalKey(key)
+	if err != nil {
+		return nil, err
+	}
+	row, err := r.reader.Get(keyB)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return decodeRow(r.cf, row)
+}
+
+func (r *typedReader) MultiGet(keys []interface{}) ([]*TypedRow, error) {
+	if r.err == nil && r.cf == nil {
+		r.fail(errors.New("No column family specified"))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	keysB := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		keyB, err := r.marshalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keysB = append(keysB, keyB)
+	}
+	rows, err := r.reader.MultiGet(keysB)
+	if err != nil {
+		return nil, err
+	}
+	typedRows := make([]*TypedRow, 0, len(rows))
+	for _, row := range rows {
+		tr, err := decodeRow(r.cf, row)
+		if err != nil {
+			return nil, err
+		}
+		typedRows = append(typedRows, tr)
+	}
+	return typedRows, nil
+}
+
+type typedWriter struct {
+	pool   *connectionPool
+	writer Writer
+	err    error
+}
+
+func newTypedWriter(cp *connectionPool) *typedWriter {
+	return &typedWriter{pool: cp, writer: cp.Writer()}
+}
+
+// marshal looks up the column family and marshals the passed key and column names, keeping the
+// first error found. It returns nil on error.
+func (w *typedWriter) marshal(cfName string, key interface{}, names []interface{}) (*ColumnFamily, []byte, [][]byte) {
+	cf, err := typedColumnFamily(w.pool, cfName)
+	if err != nil {
+		w.fail(errors.New(fmt.Sprint("Error in column family ", cfName, ": ", err)))
+		return nil, nil, nil
+	}
+	keyB, err := encodeValue(key, cf.KeyValidator)
+	if err != nil {
+		w.fail(errors.New(fmt.Sprint("Error marshaling row key ", key, " for ", cfName, ": ", err)))
+		return nil, nil, nil
+	}
+	namesB := make([][]byte, 0, len(names))
+	for _, name := range names {
+		b, err := encodeValue(name, cf.DefaultComparator)
+		if err != nil {
+			w.fail(errors.New(fmt.Sprint("Error marshaling column name ", name, " for ", cfName, ": ", err)))
+			return nil, nil, nil
+		}
+		namesB = append(namesB, b)
+	}
+	return cf, keyB, namesB
+}
+
+func (w *typedWriter) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *typedWriter) ConsistencyLevel(l int) TypedWriter {
+	w.writer.ConsistencyLevel(l)
+	return w
+}
+
+func (w *typedWriter) Insert(cf string, key, name, value interface{}) TypedWriter {
+	return w.InsertTtl(cf, key, name, value, -1)
+}
+
+func (w *typedWriter) InsertTtl(cfName string, key, name, value interface{}, ttl int) TypedWriter {
+	cf, keyB, names := w.marshal(cfName, key, []interface{}{name})
+	if cf == nil {
+		return w
+	}
+	valueB, err := encodeValue(value, cf.valueClass(names[0]))
+	if err != nil {
+		w.fail(errors.New(fmt.Sprint("Error marshaling value of column ", name, " for ", cfName, ": ", err)))
+		return w
+	}
+	w.writer.InsertTtl(cfName, &Row{Key: keyB, Columns: []*Column{&Column{Name: names[0], Value: valueB}}}, ttl)
+	return w
+}
+
+func (w *typedWriter) Increment(cfName string, key, name interface{}, delta int64) TypedWriter {
+	cf, keyB, names := w.marshal(cfName, key, []interface{}{name})
+	if cf == nil {
+		return w
+	}
+	deltaB, _ := Marshal(delta, LongType)
+	w.writer.DeltaCounters(cfName, &Row{Key: keyB, Columns: []*Column{&Column{Name: names[0], Value: deltaB}}})
+	return w
+}
+
+func (w *typedWriter) Delete(cfName string, key interface{}) TypedWriter {
+	cf, keyB, _ := w.marshal(cfName, key, nil)
+	if cf == nil {
+		return w
+	}
+	w.writer.Delete(cfName, keyB)
+	return w
+}
+
+func (w *typedWriter) DeleteColumns(cfName string, key interface{}, names ...interface{}) TypedWriter {
+	cf, keyB, namesB := w.marshal(cfName, key, names)
+	if cf == nil {
+		return w
+	}
+	w.writer.DeleteColumns(cfName, keyB, namesB)
+	return w
+}
+
+func (w *typedWriter) Raw() Writer {
+	return w.writer
+}
+
+func (w *typedWriter) Run() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.writer.Run()
+}
@@ -0,0 +1,178 @@
+package gossie
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedEncoding(t *testing.T) {
+	u, _ := NewTimeUUID()
+	composite := parseTypeClass("CompositeType(LongType,TimeUUIDType(reversed=true),AsciiType)")
+
+	checks := []struct {
+		value interface{}
+		tc    TypeClass
+	}{
+		{[]byte{1, 2, 3}, TypeClass{Desc: BytesType}},
+		{"text", TypeClass{Desc: UTF8Type}},
+		{"text", TypeClass{Desc: AsciiType}},
+		{int64(-42), TypeClass{Desc: LongType}},
+		{int64(42), TypeClass{Desc: CounterColumnType}},
+		{int32(42), TypeClass{Desc: Int32Type}},
+		{u, TypeClass{Desc: TimeUUIDType}},
+		{true, TypeClass{Desc: BooleanType}},
+		{float32(1.5), TypeClass{Desc: FloatType}},
+		{float64(-1.5), TypeClass{Desc: DoubleType}},
+		{[]interface{}{int64(1), u, "name"}, composite},
+		{[]interface{}{int64(1)}, composite},
+	}
+	for _, check := range checks {
+		b, err := encodeValue(check.value, check.tc)
+		if err != nil {
+			t.Error("Error encoding ", check.value, ": ", err)
+			continue
+		}
+		v, err := decodeValue(b, check.tc)
+		if err != nil {
+			t.Error("Error decoding ", check.value, ": ", err)
+			continue
+		}
+		if !reflect.DeepEqual(v, check.value) {
+			t.Error("Decoded value ", v, " does not match ", check.value)
+		}
+	}
+
+	now := time.Unix(1350000000, 0)
+	b, _ := encodeValue(now, TypeClass{Desc: DateType})
+	if v, err := decodeValue(b, TypeClass{Desc: DateType}); err != nil || !v.(time.Time).Equal(now) {
+		t.Error("Decoded time ", v, " does not match ", now, ": ", err)
+	}
+
+	// a single value is the first component of a composite
+	single, _ := encodeValue(int64(1), composite)
+	prefix, _ := encodeValue([]interface{}{int64(1)}, composite)
+	if !reflect.DeepEqual(single, prefix) {
+		t.Error("Single value and one component composite do not match")
+	}
+	if _, err := encodeValue([]interface{}{int64(1), u, "name", "extra"}, composite); err == nil {
+		t.Error("Expected an error encoding too many components")
+	}
+	if _, err := encodeValue("text", TypeClass{Desc: LongType}); err == nil {
+		t.Error("Expected an error encoding a value of the wrong type")
+	}
+
+	script, err := ioutil.ReadFile("../../schema-test.txt")
+	if err != nil {
+		t.Fatal("Error reading the test schema: ", err)
+	}
+	schema, err := ParseCliScript(string(script))
+	if err != nil {
+		t.Fatal("Error parsing the test schema: ", err)
+	}
+	row := &Row{Key: []byte("k")}
+	for _, c := range []struct {
+		name  string
+		value interface{}
+		desc  TypeDesc
+	}{
+		{"colLongType", int64(7), LongType},
+		{"colBooleanType", true, BooleanType},
+		{"other", "a string", UTF8Type},
+	} {
+		v, _ := Marshal(c.value, c.desc)
+		row.Columns = append(row.Columns, &Column{Name: []byte(c.name), Value: v, Ttl: 10, Timestamp: 20})
+	}
+	tr, err := decodeRow(schema.ColumnFamilies["AllTypes"], row)
+	if err != nil {
+		t.Fatal("Error decoding row: ", err)
+	}
+	expected := &TypedRow{Key: []byte("k"), Columns: []*TypedColumn{
+		&TypedColumn{Name: "colLongType", Value: int64(7), Ttl: 10, Timestamp: 20},
+		&TypedColumn{Name: "colBooleanType", Value: true, Ttl: 10, Timestamp: 20},
+		&TypedColumn{Name: "other", Value: "a string", Ttl: 10, Timestamp: 20},
+	}}
+	if !reflect.DeepEqual(tr, expected) {
+		t.Error("Unexpected decoded row: ", tr)
+	}
+}
+
+func TestTypedReaderWriter(t *testing.T) {
+	cp, err := NewConnectionPool(localEndpointPool, keyspace, PoolOptions{Size: 1, Timeout: shortTimeout})
+	if err != nil {
+		t.Fatal("Error connecting to Cassandra:", err)
+	}
+	defer cp.Close()
+
+	err = cp.TypedWriter().
+		Delete("AllTypes", []byte("typed")).
+		Delete("ReasonableTwo", "typed").
+		Run()
+	if err != nil {
+		t.Fatal("Error deleting rows: ", err)
+	}
+
+	err = cp.TypedWriter().
+		Insert("AllTypes", []byte("typed"), "colLongType", int64(42)).
+		Insert("AllTypes", []byte("typed"), "colBooleanType", true).
+		Insert("AllTypes", []byte("typed"), "colDoubleType", 2.5).
+		Insert("AllTypes", []byte("typed"), "unnamed", "default validator").
+		Insert("ReasonableTwo", "typed", []interface{}{int64(1), int64(10), "Body"}, []byte("a")).
+		Insert("ReasonableTwo", "typed", []interface{}{int64(1), int64(20), "Body"}, []byte("b")).
+		Insert("ReasonableTwo", "typed", []interface{}{int64(2), int64(10), "Body"}, []byte("c")).
+		Run()
+	if err != nil {
+		t.Fatal("Error writing typed rows: ", err)
+	}
+
+	row, err := cp.TypedReader().Cf("AllTypes").Get([]byte("typed"))
+	if err != nil {
+		t.Fatal("Error reading typed row: ", err)
+	}
+	values := make(map[interface{}]interface{})
+	for _, c := range row.Columns {
+		values[c.Name] = c.Value
+	}
+	expected := map[interface{}]interface{}{
+		"colLongType":    int64(42),
+		"colBooleanType": true,
+		"colDoubleType":  2.5,
+		"unnamed":        "default validator",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Error("Unexpected typed row: ", values)
+	}
+
+	row, err = cp.TypedReader().Cf("AllTypes").Columns("colLongType").Get([]byte("typed"))
+	if err != nil || len(row.Columns) != 1 || row.Columns[0].Value != int64(42) {
+		t.Error("Unexpected typed row reading a single column: ", row, err)
+	}
+
+	row, err = cp.TypedReader().Cf("ReasonableTwo").Slice([]interface{}{int64(1)}, []interface{}{int64(1)}, 100, false).Get("typed")
+	if err != nil {
+		t.Fatal("Error reading typed row: ", err)
+	}
+	if len(row.Columns) != 2 || !reflect.DeepEqual(row.Columns[1].Name, []interface{}{int64(1), int64(20), "Body"}) || row.Key != "typed" {
+		t.Error("Unexpected typed slice: ", row)
+	}
+	row, err = cp.TypedReader().Cf("ReasonableTwo").Slice(int64(2), int64(1), 100, true).Get("typed")
+	if err != nil || len(row.Columns) != 3 || !reflect.DeepEqual(row.Columns[0].Value, []byte("c")) {
+		t.Error("Unexpected reversed typed slice: ", row, err)
+	}
+
+	rows, err := cp.TypedReader().Cf("ReasonableTwo").MultiGet([]interface{}{"typed", "missing"})
+	if err != nil || len(rows) != 1 {
+		t.Error("Unexpected typed MultiGet: ", rows, err)
+	}
+
+	if err = cp.TypedWriter().Insert("AllTypes", []byte("typed"), "colLongType", "not a long").Run(); err == nil {
+		t.Error("Expected an error writing a value of the wrong type")
+	}
+	if _, err = cp.TypedReader().Cf("NotACf").Get("typed"); err != ErrorCfNotFound {
+		t.Error("Expected ErrorCfNotFound reading an unknown column family but got ", err)
+	}
+	if _, err = cp.TypedReader().Cf("Super").Get([]byte("typed")); err == nil {
+		t.Error("Expected an error reading a super column family")
+	}
+}
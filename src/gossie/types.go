@@ -59,6 +59,40 @@ var (
 
 type TypeDesc int
 
+var typeDescNames = map[TypeDesc]string{
+	UnknownType:       "UnknownType",
+	BytesType:         "BytesType",
+	AsciiType:         "AsciiType",
+	UTF8Type:          "UTF8Type",
+	LongType:          "LongType",
+	Int32Type:         "Int32Type",
+	IntegerType:       "IntegerType",
+	DecimalType:       "DecimalType",
+	UUIDType:          "UUIDType",
+	TimeUUIDType:      "TimeUUIDType",
+	LexicalUUIDType:   "LexicalUUIDType",
+	BooleanType:       "BooleanType",
+	FloatType:         "FloatType",
+	DoubleType:        "DoubleType",
+	DateType:          "DateType",
+	CounterColumnType: "CounterColumnType",
+	CompositeType:     "CompositeType",
+}
+
+// String returns the Cassandra name of the type
+func (t TypeDesc) String() string {
+	if name, found := typeDescNames[t]; found {
+		return name
+	}
+	return "UnknownType"
+}
+
+// className returns the Cassandra name of a type, for building type classes from the untyped type
+// constants
+func className(t TypeDesc) string {
+	return t.String()
+}
+
 func Marshal(value interface{}, typeDesc TypeDesc) ([]byte, error) {
 	// plain nil case
 	if value == nil {
@@ -199,7 +233,7 @@ func marshalBool(value bool, typeDesc TypeDesc) ([]byte, error) {
 func marshalInt(value int64, size int, typeDesc TypeDesc) ([]byte, error) {
 	switch typeDesc {
 
-	case LongType:
+	case LongType, CounterColumnType:
 		b := make([]byte, 8)
 		enc.BigEndian.PutUint64(b, uint64(value))
 		return b, nil
@@ -625,71 +659,110 @@ type TypeClass struct {
 	Reversed   bool
 }
 
-func extractReversed(cassType string) (string, bool) {
-	reversed := false
-	if strings.HasPrefix(cassType, "org.apache.cassandra.db.marshal.ReversedType(") {
-		// extract the inner type
-		cassType = cassType[strings.Index(cassType, "(")+1 : len(cassType)-1]
-		reversed = true
+const marshalPackage = "org.apache.cassandra.db.marshal."
+
+// splitTypeParameters splits a Cassandra type into its short name and its parameters, if any. The
+// parameters are split at the top level commas only, so nested types are kept whole.
+func splitTypeParameters(cassType string) (string, []string) {
+	cassType = strings.TrimPrefix(strings.TrimSpace(cassType), marshalPackage)
+	open := strings.Index(cassType, "(")
+	if open < 0 || !strings.HasSuffix(cassType, ")") {
+		return cassType, nil
+	}
+	name := strings.TrimSpace(cassType[:open])
+	inner := cassType[open+1 : len(cassType)-1]
+
+	params := make([]string, 0)
+	depth := 0
+	last := 0
+	for i, c := range inner {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(inner[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(inner[last:]); rest != "" || len(params) > 0 {
+		params = append(params, rest)
 	}
-	return cassType, reversed
+	return name, params
 }
 
 func parseTypeDesc(cassType string) TypeDesc {
-	switch cassType {
-	case "BytesType", "org.apache.cassandra.db.marshal.BytesType":
+	return parseTypeClass(cassType).Desc
+}
+
+func typeDescFromName(name string) TypeDesc {
+	switch name {
+	case "BytesType":
 		return BytesType
-	case "AsciiType", "org.apache.cassandra.db.marshal.AsciiType":
+	case "AsciiType":
 		return AsciiType
-	case "UTF8Type", "org.apache.cassandra.db.marshal.UTF8Type":
+	case "UTF8Type":
 		return UTF8Type
-	case "LongType", "org.apache.cassandra.db.marshal.LongType":
+	case "LongType":
 		return LongType
-	case "Int32Type", "org.apache.cassandra.db.marshal.Int32Type":
+	case "Int32Type":
 		return Int32Type
-	case "IntegerType", "org.apache.cassandra.db.marshal.IntegerType":
+	case "IntegerType":
 		return IntegerType
-	case "DecimalType", "org.apache.cassandra.db.marshal.DecimalType":
+	case "DecimalType":
 		return DecimalType
-	case "UUIDType", "org.apache.cassandra.db.marshal.UUIDType":
+	case "UUIDType":
 		return UUIDType
-	case "TimeUUIDType", "org.apache.cassandra.db.marshal.TimeUUIDType":
+	case "TimeUUIDType":
 		return TimeUUIDType
-	case "LexicalUUIDType", "org.apache.cassandra.db.marshal.LexicalUUIDType":
+	case "LexicalUUIDType":
 		return LexicalUUIDType
-	case "BooleanType", "org.apache.cassandra.db.marshal.BooleanType":
+	case "BooleanType":
 		return BooleanType
-	case "FloatType", "org.apache.cassandra.db.marshal.FloatType":
+	case "FloatType":
 		return FloatType
-	case "DoubleType", "org.apache.cassandra.db.marshal.DoubleType":
+	case "DoubleType":
 		return DoubleType
-	case "DateType", "org.apache.cassandra.db.marshal.DateType":
+	case "DateType":
 		return DateType
-	case "CounterColumnType", "org.apache.cassandra.db.marshal.CounterColumnType":
+	case "CounterColumnType":
 		return CounterColumnType
 	}
 	return BytesType
 }
 
+// parseTypeClass parses all the forms Cassandra and cassandra-cli use to write a type: with or
+// without the package name, wrapped in ReversedType(...), with a (reversed=true) parameter, and
+// composites of any of those.
 func parseTypeClass(cassType string) TypeClass {
-	cassType, reversed := extractReversed(cassType)
-	r := TypeClass{Reversed: reversed}
-
-	// check for composite and parse it
-	if strings.HasPrefix(cassType, "org.apache.cassandra.db.marshal.CompositeType(") {
-		r.Desc = CompositeType
-		componentsString := cassType[strings.Index(cassType, "(")+1 : len(cassType)-1]
-		componentsSlice := strings.Split(componentsString, ",")
-		var components []TypeClass
-		for _, component := range componentsSlice {
-			components = append(components, parseTypeClass(component))
-		}
-		r.Components = components
+	name, params := splitTypeParameters(cassType)
+
+	switch name {
+	case "ReversedType":
+		if len(params) == 1 {
+			r := parseTypeClass(params[0])
+			r.Reversed = true
+			return r
+		}
+	case "CompositeType":
+		r := TypeClass{Desc: CompositeType}
+		for _, component := range params {
+			r.Components = append(r.Components, parseTypeClass(component))
+		}
 		return r
 	}
 
-	r.Desc = parseTypeDesc(cassType)
-
+	r := TypeClass{Desc: typeDescFromName(name)}
+	for _, param := range params {
+		if option := strings.SplitN(param, "=", 2); len(option) == 2 {
+			if strings.TrimSpace(option[0]) == "reversed" && strings.TrimSpace(option[1]) == "true" {
+				r.Reversed = true
+			}
+		}
+	}
 	return r
 }
 
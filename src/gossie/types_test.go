@@ -374,3 +374,34 @@ func TestMarshalTime(t *testing.T) {
 	errorMarshal(t, v, BooleanType)
 	errorMarshal(t, v, DoubleType)
 }
+
+func TestParseTypeClass(t *testing.T) {
+	checks := map[string]TypeClass{
+		"LongType": TypeClass{Desc: LongType},
+		"org.apache.cassandra.db.marshal.UTF8Type": TypeClass{Desc: UTF8Type},
+		"TimeUUIDType(reversed=true)":              TypeClass{Desc: TimeUUIDType, Reversed: true},
+		"TimeUUIDType(reversed=false)":             TypeClass{Desc: TimeUUIDType},
+		"ReversedType(LongType)":                   TypeClass{Desc: LongType, Reversed: true},
+		"org.apache.cassandra.db.marshal.ReversedType(org.apache.cassandra.db.marshal.DateType)": TypeClass{Desc: DateType, Reversed: true},
+		"CompositeType(TimeUUIDType(reversed=true),AsciiType)": TypeClass{Desc: CompositeType, Components: []TypeClass{
+			TypeClass{Desc: TimeUUIDType, Reversed: true},
+			TypeClass{Desc: AsciiType},
+		}},
+		"org.apache.cassandra.db.marshal.CompositeType(org.apache.cassandra.db.marshal.ReversedType(org.apache.cassandra.db.marshal.LongType), org.apache.cassandra.db.marshal.UTF8Type)": TypeClass{Desc: CompositeType, Components: []TypeClass{
+			TypeClass{Desc: LongType, Reversed: true},
+			TypeClass{Desc: UTF8Type},
+		}},
+		"ReversedType(CompositeType(LongType,Int32Type))": TypeClass{Desc: CompositeType, Reversed: true, Components: []TypeClass{
+			TypeClass{Desc: LongType},
+			TypeClass{Desc: Int32Type},
+		}},
+	}
+	for cassType, expected := range checks {
+		if tc := parseTypeClass(cassType); !reflect.DeepEqual(tc, expected) {
+			t.Error("Unexpected type class for ", cassType, ": ", tc)
+		}
+	}
+	if d := parseTypeDesc("ReversedType(TimeUUIDType)"); d != TimeUUIDType {
+		t.Error("Unexpected type desc for a reversed type: ", d)
+	}
+}
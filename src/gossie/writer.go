@@ -1,9 +1,19 @@
 package gossie
 
 import (
+	"bytes"
 	"github.com/carloscm/gossie/src/cassandra"
 	"github.com/pomack/thrift4go/lib/go/src/thrift"
-	"time"
+	"strings"
+)
+
+const (
+	DEFAULT_DELETE_SLICE_PAGE = 1000
+
+	// COUNTER_ATTEMPTS is the number of times a Writer containing counter operations is tried.
+	// Counter updates are not idempotent, so a failed or timed out counter write is never retried
+	// since it could have been applied already. The pool Retries option is ignored for them.
+	COUNTER_ATTEMPTS = 1
 )
 
 // Writer is the interface for all the write operations over Cassandra.
@@ -15,47 +25,101 @@ type Writer interface {
 	// pool options value.
 	ConsistencyLevel(int) Writer
 
-	// Insert adds a new row insertion to the mutation
+	// Timestamps sets the provider for the timestamps of the operations added after this call.
+	// It is optional, if left uncalled it will default to your connection pool options value.
+	Timestamps(TimestampProvider) Writer
+
+	// Insert adds a new row insertion to the mutation. Each column is written with its own Ttl,
+	// without expiration if it is 0.
 	Insert(cf string, row *Row) Writer
 
 	// InsertTtl adds a new row insertion to the mutation, overriding the
 	// columns Ttl with the passed value
 	InsertTtl(cf string, row *Row, ttl int) Writer
 
-	// DeltaCounters add a new delta operation over counters. When using this
-	// function the number of retries for the connection is temporally set to
-	// 1.
+	// InsertSuper adds a new insertion of the super columns in the passed row to the mutation, for
+	// super column families
+	InsertSuper(cf string, row *Row) Writer
+
+	// DeltaCounters add a new delta operation over counters. The column values must be marshaled as
+	// LongType. A Writer with counter operations is never retried, see COUNTER_ATTEMPTS.
 	DeltaCounters(cf string, row *Row) Writer
 
+	// RemoveCounter removes a counter column from the row specified by key, or the whole counter row
+	// if name is nil. Counter removals are run one by one with the remove_counter call after the
+	// rest of the operations of the Writer, and they are never retried, see COUNTER_ATTEMPTS.
+	// Counters inside super columns cannot be removed with it. Counter columns cannot be removed
+	// with the Delete calls.
+	RemoveCounter(cf string, key []byte, name []byte) Writer
+
 	// Delete deletes a single row specified by key
 	Delete(cf string, key []byte) Writer
 
+	// DeleteAt deletes a single row specified by key, using the passed timestamp for the deletion
+	DeleteAt(cf string, key []byte, timestamp int64) Writer
+
 	// DeleteColumns deletes the passed columns from the row specified by key.
 	DeleteColumns(cf string, key []byte, columns [][]byte) Writer
 
-	// Run this mutation
+	// DeleteColumnsAt deletes the passed columns from the row specified by key, using the passed
+	// timestamp for the deletion
+	DeleteColumnsAt(cf string, key []byte, columns [][]byte, timestamp int64) Writer
+
+	// DeleteSuper deletes a single super column from the row specified by key, for super column
+	// families
+	DeleteSuper(cf string, key []byte, superColumn []byte) Writer
+
+	// DeleteSlice deletes all the columns in the passed slice from the row specified by key, for
+	// example all the columns between two composite prefixes. SliceRange deletions are sent as such,
+	// but if the server rejects them (like Cassandra 1.0 does) they are emulated from then on by
+	// reading the names of the matching columns in pages of slice.Count columns and deleting them by
+	// name when Run is called.
+	DeleteSlice(cf string, key []byte, slice *Slice) Writer
+
+	// DeleteSliceAt works like DeleteSlice using the passed timestamp for the deletion. When emulated
+	// only the columns written before the timestamp are deleted, so concurrent writes are not lost.
+	DeleteSliceAt(cf string, key []byte, slice *Slice, timestamp int64) Writer
+
+	// Mutations returns a description of the operations added so far, in the order they were
+	// added. Writes of the same column in the same row are merged as they are added, keeping the one
+	// with the newest timestamp (or the last one for equal timestamps), and counter deltas over the
+	// same column are added up.
+	Mutations() []*Mutation
+
+	// DryRun validates the operations added so far against the keyspace schema without sending
+	// them, returning the first error found
+	DryRun() error
+
+	// Run this mutation. If it contains counter operations it is tried only once, so on error the
+	// caller cannot know if the counter operations were applied or not.
 	Run() error
 }
 
 type writer struct {
 	pool             *connectionPool
 	consistencyLevel int
+	timestamps       TimestampProvider
 	writers          thrift.TMap
+	order            []*writerMutation
+	columns          map[writerColumn]*cassandra.Column
+	counters         map[writerColumn]*cassandra.CounterColumn
 	usedCounters     bool
+	counterRemovals  []*writerMutation
+	sliceDeletions   []*sliceDeletion
+	async            *async
 }
 
 func newWriter(cp *connectionPool, cl int) *writer {
 	return &writer{
 		pool:             cp,
 		consistencyLevel: cl,
+		timestamps:       cp.options.Timestamps,
 		writers:          thrift.NewTMap(thrift.BINARY, thrift.MAP, 1),
+		columns:          make(map[writerColumn]*cassandra.Column),
+		counters:         make(map[writerColumn]*cassandra.CounterColumn),
 	}
 }
 
-func now() int64 {
-	return time.Now().UnixNano() / 1000
-}
-
 func (w *writer) addWriter(cf string, key []byte) *cassandra.Mutation {
 	tm := cassandra.NewMutation()
 	var cfMuts thrift.TMap
@@ -75,6 +139,7 @@ func (w *writer) addWriter(cf string, key []byte) *cassandra.Mutation {
 		mutList = im.(thrift.TList)
 	}
 	mutList.Push(tm)
+	w.order = append(w.order, &writerMutation{cf: cf, key: key, mutation: tm})
 	return tm
 }
 
@@ -83,27 +148,51 @@ func (w *writer) ConsistencyLevel(l int) Writer {
 	return w
 }
 
+func (w *writer) Timestamps(t TimestampProvider) Writer {
+	w.timestamps = t
+	return w
+}
+
+func (w *writer) now() int64 {
+	return w.timestamps.Timestamp()
+}
+
 func (w *writer) Insert(cf string, row *Row) Writer {
 	return w.InsertTtl(cf, row, -1)
 }
 
+func buildColumn(col *Column, ttl int, t int64) *cassandra.Column {
+	c := cassandra.NewColumn()
+	c.Name = col.Name
+	c.Value = col.Value
+	if ttl > 0 {
+		c.Ttl = int32(ttl)
+	} else {
+		c.Ttl = col.Ttl
+	}
+	if col.Timestamp > 0 {
+		c.Timestamp = col.Timestamp
+	} else {
+		c.Timestamp = t
+	}
+	return c
+}
+
 func (w *writer) InsertTtl(cf string, row *Row, ttl int) Writer {
-	t := now()
+	t := w.now()
 	for _, col := range row.Columns {
-		tm := w.addWriter(cf, row.Key)
-		c := cassandra.NewColumn()
-		c.Name = col.Name
-		c.Value = col.Value
-		if ttl > 0 {
-			c.Ttl = int32(ttl)
-		} else {
-			c.Ttl = c.Ttl
-		}
-		if col.Timestamp > 0 {
-			c.Timestamp = col.Timestamp
-		} else {
-			c.Timestamp = t
+		c := buildColumn(col, ttl, t)
+		id := writerColumn{cf, string(row.Key), string(c.Name)}
+		if existing, found := w.columns[id]; found {
+			if c.Timestamp >= existing.Timestamp {
+				existing.Value = c.Value
+				existing.Timestamp = c.Timestamp
+				existing.Ttl = c.Ttl
+			}
+			continue
 		}
+		w.columns[id] = c
+		tm := w.addWriter(cf, row.Key)
 		cs := cassandra.NewColumnOrSuperColumn()
 		cs.Column = c
 		tm.ColumnOrSupercolumn = cs
@@ -111,12 +200,35 @@ func (w *writer) InsertTtl(cf string, row *Row, ttl int) Writer {
 	return w
 }
 
+func (w *writer) InsertSuper(cf string, row *Row) Writer {
+	t := w.now()
+	for _, superCol := range row.SuperColumns {
+		tm := w.addWriter(cf, row.Key)
+		sc := cassandra.NewSuperColumn()
+		sc.Name = superCol.Name
+		sc.Columns = thrift.NewTList(thrift.STRUCT, len(superCol.Columns))
+		for _, col := range superCol.Columns {
+			sc.Columns.Push(buildColumn(col, -1, t))
+		}
+		cs := cassandra.NewColumnOrSuperColumn()
+		cs.SuperColumn = sc
+		tm.ColumnOrSupercolumn = cs
+	}
+	return w
+}
+
 func (w *writer) DeltaCounters(cf string, row *Row) Writer {
 	for _, col := range row.Columns {
-		tm := w.addWriter(cf, row.Key)
 		c := cassandra.NewCounterColumn()
 		c.Name = col.Name
 		Unmarshal(col.Value, LongType, &c.Value)
+		id := writerColumn{cf, string(row.Key), string(c.Name)}
+		if existing, found := w.counters[id]; found {
+			existing.Value += c.Value
+			continue
+		}
+		w.counters[id] = c
+		tm := w.addWriter(cf, row.Key)
 		cs := cassandra.NewColumnOrSuperColumn()
 		cs.CounterColumn = c
 		tm.ColumnOrSupercolumn = cs
@@ -125,18 +237,33 @@ func (w *writer) DeltaCounters(cf string, row *Row) Writer {
 	return w
 }
 
+func (w *writer) RemoveCounter(cf string, key []byte, name []byte) Writer {
+	wm := &writerMutation{cf: cf, key: key, column: name, counterRemoval: true}
+	w.order = append(w.order, wm)
+	w.counterRemovals = append(w.counterRemovals, wm)
+	return w
+}
+
 func (w *writer) Delete(cf string, key []byte) Writer {
+	return w.DeleteAt(cf, key, w.now())
+}
+
+func (w *writer) DeleteAt(cf string, key []byte, timestamp int64) Writer {
 	tm := w.addWriter(cf, key)
 	d := cassandra.NewDeletion()
-	d.Timestamp = now()
+	d.Timestamp = timestamp
 	tm.Deletion = d
 	return w
 }
 
 func (w *writer) DeleteColumns(cf string, key []byte, columns [][]byte) Writer {
+	return w.DeleteColumnsAt(cf, key, columns, w.now())
+}
+
+func (w *writer) DeleteColumnsAt(cf string, key []byte, columns [][]byte, timestamp int64) Writer {
 	tm := w.addWriter(cf, key)
 	d := cassandra.NewDeletion()
-	d.Timestamp = now()
+	d.Timestamp = timestamp
 	sp := cassandra.NewSlicePredicate()
 	sp.ColumnNames = thrift.NewTList(thrift.BINARY, 1)
 	for _, name := range columns {
@@ -147,27 +274,169 @@ func (w *writer) DeleteColumns(cf string, key []byte, columns [][]byte) Writer {
 	return w
 }
 
-/* InvalidRequestException({TStruct:InvalidRequestException Why:Deletion does not yet support SliceRange predicates.})
+func (w *writer) DeleteSuper(cf string, key []byte, superColumn []byte) Writer {
+	tm := w.addWriter(cf, key)
+	d := cassandra.NewDeletion()
+	d.Timestamp = w.now()
+	d.SuperColumn = superColumn
+	tm.Deletion = d
+	return w
+}
+
 func (w *writer) DeleteSlice(cf string, key []byte, slice *Slice) Writer {
-    tm := w.addWriter(cf, key)
-    d := cassandra.NewDeletion()
-    d.Timestamp = now()
-    sp := cassandra.NewSlicePredicate()
-    sp.SliceRange = sliceToCassandra(slice)
-    d.Predicate = sp
-    tm.Deletion = d
-    return w
+	return w.DeleteSliceAt(cf, key, slice, w.now())
 }
-*/
 
-func (w *writer) Run() error {
+func (w *writer) DeleteSliceAt(cf string, key []byte, slice *Slice, timestamp int64) Writer {
+	tm := w.addWriter(cf, key)
+	d := cassandra.NewDeletion()
+	d.Timestamp = timestamp
+	sp := cassandra.NewSlicePredicate()
+	sp.SliceRange = sliceToCassandra(slice)
+	d.Predicate = sp
+	tm.Deletion = d
+	w.sliceDeletions = append(w.sliceDeletions, &sliceDeletion{
+		cf:       cf,
+		key:      key,
+		slice:    *slice,
+		deletion: d,
+	})
+	return w
+}
+
+// sliceDeletion tracks a SliceRange deletion so it can be emulated if the server rejects it
+type sliceDeletion struct {
+	cf       string
+	key      []byte
+	slice    Slice
+	deletion *cassandra.Deletion
+}
+
+// isSliceDeletionUnsupported checks for the error returned by servers that do not support SliceRange
+// predicates in deletions, like Cassandra 1.0:
+// InvalidRequestException({TStruct:InvalidRequestException Why:Deletion does not yet support SliceRange predicates.})
+func isSliceDeletionUnsupported(err error) bool {
+	terr, ok := err.(*transactionError)
+	return ok && terr.ire != nil && strings.Contains(terr.ire.Why, "SliceRange")
+}
+
+// emulateSliceDeletions replaces the SliceRange predicate of the pending slice deletions with the
+// names of the columns currently matching the slice, read in pages of slice.Count columns. The
+// deletion timestamp is kept so columns written after the deletion was issued are not affected.
+func (w *writer) emulateSliceDeletions() error {
+	for _, sd := range w.sliceDeletions {
+		if sd.deletion.Predicate.SliceRange == nil {
+			continue
+		}
+		names, err := w.sliceColumnNames(sd)
+		if err != nil {
+			return err
+		}
+		sp := cassandra.NewSlicePredicate()
+		sp.ColumnNames = thrift.NewTList(thrift.BINARY, len(names))
+		for _, name := range names {
+			sp.ColumnNames.Push(name)
+		}
+		sd.deletion.Predicate = sp
+	}
+	return nil
+}
+
+func (w *writer) sliceColumnNames(sd *sliceDeletion) ([][]byte, error) {
+	slice := sd.slice
+	if slice.Count <= 1 {
+		slice.Count = DEFAULT_DELETE_SLICE_PAGE
+	}
+
+	names := make([][]byte, 0)
+	var last []byte
+	for {
+		row, err := w.pool.Reader().Cf(sd.cf).Slice(&slice).Get(sd.key)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		for _, c := range row.Columns {
+			// the slice start is inclusive so skip the last column of the previous page
+			if last != nil && bytes.Equal(c.Name, last) {
+				continue
+			}
+			names = append(names, c.Name)
+		}
+		if len(row.Columns) < slice.Count {
+			break
+		}
+		last = row.Columns[len(row.Columns)-1].Name
+		slice.Start = last
+	}
+
+	return names, nil
+}
+
+func (w *writer) run() error {
 	toRun := func(c *connection) *transactionError {
 		ire, ue, te, err := c.client.BatchMutate(
 			w.writers, cassandra.ConsistencyLevel(w.consistencyLevel))
 		return &transactionError{ire, ue, te, err}
 	}
 	if w.usedCounters {
-		return w.pool.runWithRetries(toRun, 1)
+		return w.pool.runWithRetries(toRun, COUNTER_ATTEMPTS)
 	}
 	return w.pool.run(toRun)
 }
+
+func (w *writer) removeCounter(wm *writerMutation) error {
+	path := cassandra.NewColumnPath()
+	path.ColumnFamily = wm.cf
+	path.Column = wm.column
+	return w.pool.runWithRetries(func(c *connection) *transactionError {
+		ire, ue, te, err := c.client.RemoveCounter(
+			wm.key, path, cassandra.ConsistencyLevel(w.consistencyLevel))
+		return &transactionError{ire, ue, te, err}
+	}, COUNTER_ATTEMPTS)
+}
+
+func (w *writer) Run() error {
+	if w.async != nil {
+		return w.async.enqueue(&asyncJob{runner: runnerFunc(w.execute)})
+	}
+	return w.execute()
+}
+
+func (w *writer) execute() error {
+	// writers with only counter removals have nothing to send in a batch
+	if len(w.order) == 0 || len(w.order) > len(w.counterRemovals) {
+		if err := w.executeBatch(); err != nil {
+			return err
+		}
+	}
+	for _, wm := range w.counterRemovals {
+		if err := w.removeCounter(wm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) executeBatch() error {
+	if len(w.sliceDeletions) > 0 && !w.pool.supportsSliceDeletions() {
+		if err := w.emulateSliceDeletions(); err != nil {
+			return err
+		}
+	}
+
+	err := w.run()
+
+	// the whole batch is rejected at validation time, so it is safe to emulate and run it again
+	if len(w.sliceDeletions) > 0 && isSliceDeletionUnsupported(err) {
+		w.pool.unsupportedSliceDeletions()
+		if err := w.emulateSliceDeletions(); err != nil {
+			return err
+		}
+		err = w.run()
+	}
+
+	return err
+}